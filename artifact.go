@@ -1,39 +1,24 @@
 package nexus
 
 import (
+	"context"
+	"encoding/json"
 	"encoding/xml"
 	"fmt"
-	"strings"
+	"hash/fnv"
 	"time"
 
+	"sbrubbles.org/go/nexus/search"
 	"sbrubbles.org/go/nexus/util"
 )
 
 // Artifact is a Maven coordinate to a single artifact, plus the repository
-// where it came from.
-type Artifact struct {
-	GroupID      string // e.g. org.springframework
-	ArtifactID   string // e.g. spring-core
-	Version      string // e.g. 4.1.3.RELEASE
-	Classifier   string // e.g. sources, javadoc, <the empty string>...
-	Extension    string // e.g. jar
-	RepositoryID string // e.g. releases
-}
-
-// String implements the fmt.Stringer interface, as per Maven docs
-// (http://maven.apache.org/pom.html#Maven_Coordinates).
-func (a Artifact) String() string {
-	var parts = []string{a.GroupID, a.ArtifactID, a.Extension}
-
-	if a.Classifier != "" {
-		parts = append(parts, a.Classifier)
-	}
-
-	return strings.Join(append(parts, a.Version), ":") + "@" + a.RepositoryID
-}
+// where it came from. It lives in package search, so predicates there can
+// filter artifacts (see search.Filtered).
+type Artifact = search.Artifact
 
 // used for the artifact set.
-func (a *Artifact) hash() string {
+func hashOf(a *Artifact) string {
 	return a.GroupID + ":" + a.ArtifactID + ":" + a.Version + ":" +
 		a.Extension + ":" + a.Classifier + "@" + a.RepositoryID
 }
@@ -43,34 +28,55 @@ var empty struct{}
 
 // since Go doesn't have a built-in set implementation, a make-shift one
 // follows, using a map for the heavy duty. Artifact's hash method is used to
-// distinguish between artifacts, since there's no Java-like Equals contract to follow.
+// distinguish between artifacts, since there's no Java-like Equals contract to
+// follow. The set holds only a fixed-size fingerprint of each hash, not the
+// artifacts themselves, so it stays small even on a full search.
 type artifactSet struct {
-	// piles up the artifacts
-	data []*Artifact
-
 	// the set behavior
-	hashMap map[string]struct{}
+	hashMap map[[16]byte]struct{}
 }
 
 // creates and initializes a new set of artifacts.
 func newArtifactSet() *artifactSet {
 	return &artifactSet{
-		data:    []*Artifact{},
-		hashMap: make(map[string]struct{}),
+		hashMap: make(map[[16]byte]struct{}),
 	}
 }
 
-// adds a bunch of artifacts to this set.
-func (set *artifactSet) add(artifacts []*Artifact) {
+// the fingerprint kept in the set. FNV-128a is fast and has more than enough
+// bits to make collisions a non-issue.
+func fingerprintOf(artifact *Artifact) [16]byte {
+	var key [16]byte
+
+	h := fnv.New128a()
+	h.Write([]byte(hashOf(artifact)))
+	h.Sum(key[:0])
+
+	return key
+}
+
+// adds a bunch of artifacts to this set, returning the ones which weren't
+// already there.
+func (set *artifactSet) add(artifacts []*Artifact) []*Artifact {
+	added := []*Artifact{}
+
 	for _, artifact := range artifacts {
-		hash := artifact.hash()
-		_, contains := set.hashMap[hash]
+		key := fingerprintOf(artifact)
+		_, contains := set.hashMap[key]
 
-		set.hashMap[hash] = empty
+		set.hashMap[key] = empty
 		if !contains {
-			set.data = append(set.data, artifact)
+			added = append(added, artifact)
 		}
 	}
+
+	return added
+}
+
+// if the given artifact is in this set.
+func (set *artifactSet) contains(artifact *Artifact) bool {
+	_, contains := set.hashMap[fingerprintOf(artifact)]
+	return contains
 }
 
 // ArtifactInfo holds extra information about an artifact. There are no
@@ -98,37 +104,69 @@ func (info ArtifactInfo) String() string {
 		info.Artifact, info.Sha1, info.MimeType, info.Size)
 }
 
+// the information Nexus sends about an artifact.
+type artifactInfoPayload struct {
+	Data struct {
+		MimeType     string `xml:"mimeType" json:"mimeType"`
+		Uploader     string `xml:"uploader" json:"uploader"`
+		Uploaded     int64  `xml:"uploaded" json:"uploaded"`
+		LastChanged  int64  `xml:"lastChanged" json:"lastChanged"`
+		Size         int64  `xml:"size" json:"size"`
+		Sha1Hash     string `xml:"sha1Hash" json:"sha1Hash"`
+		Repositories []struct {
+			RepositoryID string `xml:"repositoryId" json:"repositoryId"`
+			ArtifactURL  string `xml:"artifactUrl" json:"artifactUrl"`
+		} `xml:"repositories>org.sonatype.nexus.rest.model.RepositoryUrlResource" json:"repositories"`
+	} `xml:"data" json:"data"`
+}
+
 // UnmarshalXML implements the xml.Unmarshaler interface. The ArtifactInfo
 // should already have a valid *Artifact pointer; the rest of the information
 // will be extracted from the payload.
 func (info *ArtifactInfo) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
-	if info == nil {
-		return fmt.Errorf("Can't unmarshal to a nil *ArtifactInfo!")
+	if err := info.check(); err != nil {
+		return err
 	}
 
-	if info.Artifact == nil {
-		return fmt.Errorf("Can't unmarshal an *ArtifactInfo with a nil *Artifact!")
+	var payload artifactInfoPayload
+	if err := d.DecodeElement(&payload, &start); err != nil {
+		return err
 	}
 
-	var payload struct {
-		Data struct {
-			MimeType     string `xml:"mimeType"`
-			Uploader     string `xml:"uploader"`
-			Uploaded     int64  `xml:"uploaded"`
-			LastChanged  int64  `xml:"lastChanged"`
-			Size         int64  `xml:"size"`
-			Sha1Hash     string `xml:"sha1Hash"`
-			Repositories []struct {
-				RepositoryID string `xml:"repositoryId"`
-				ArtifactURL  string `xml:"artifactUrl"`
-			} `xml:"repositories>org.sonatype.nexus.rest.model.RepositoryUrlResource"`
-		} `xml:"data"`
+	info.fill(payload)
+	return nil
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface, with the same
+// caveats as UnmarshalXML.
+func (info *ArtifactInfo) UnmarshalJSON(data []byte) error {
+	if err := info.check(); err != nil {
+		return err
 	}
 
-	if err := d.DecodeElement(&payload, &start); err != nil {
+	var payload artifactInfoPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
 		return err
 	}
 
+	info.fill(payload)
+	return nil
+}
+
+// an ArtifactInfo can only be unmarshaled if it knows its artifact.
+func (info *ArtifactInfo) check() error {
+	if info == nil {
+		return fmt.Errorf("Can't unmarshal to a nil *ArtifactInfo!")
+	}
+
+	if info.Artifact == nil {
+		return fmt.Errorf("Can't unmarshal an *ArtifactInfo with a nil *Artifact!")
+	}
+
+	return nil
+}
+
+func (info *ArtifactInfo) fill(payload artifactInfoPayload) {
 	// finding the URL of this artifact. We need to know from which repository
 	// the artifact is, so this is why we need the *Artifact already filled in
 	url := ""
@@ -146,41 +184,92 @@ func (info *ArtifactInfo) UnmarshalXML(d *xml.Decoder, start xml.StartElement) e
 	info.Size = util.ByteSize(payload.Data.Size)
 	info.MimeType = payload.Data.MimeType
 	info.URL = url
-
-	return nil
 }
 
+// receives the artifacts found by a search, in batches (e.g. a GAV in a lucene
+// page). An error aborts the search.
+type emitFunc func(artifacts []*Artifact) error
+
 // A make-shift map-reducer, distributes an artifact search in multiple
-// goroutines. Expects an array of strings and a query function. There will be
-// one goroutine for every element of data. Each goroutine will call query with
-// its respective datum.
-func concurrentArtifactSearch(data []string, query func(string) ([]*Artifact, error)) ([]*Artifact, error) {
-	artifacts := make(chan []*Artifact)
-	errors := make(chan error)
+// goroutines. Expects the number of workers, an array of strings, a query
+// function and an emitter. There will be at most workers goroutines (or one
+// if workers < 1), each picking the next element of data and calling query
+// with it and a context derived from ctx, which is cancelled as soon as any
+// query fails or the search ends, so the other queries stop early instead of
+// running (and blocking) in vain. The batches found are funneled to emit from
+// the calling goroutine only, so emit doesn't need to be safe for concurrent
+// use.
+//
+// If bestEffort is true, a failed query doesn't stop the others; the search
+// goes on, and the failures are returned at the end in a *MultiError. Errors
+// from emit or ctx still stop everything.
+func concurrentArtifactSearch(ctx context.Context, workers int, bestEffort bool, data []string, query func(context.Context, string, emitFunc) error, emit emitFunc) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	// search for the artifacts in each element of data
+	batches := make(chan []*Artifact)
+	errors := make(chan error, len(data)) // buffered, so no goroutine hangs
+
+	// the work queue: every datum is already in, so workers never block on it
+	jobs := make(chan string, len(data))
 	for _, datum := range data {
-		go func(datum string) {
-			a, err := query(datum)
-			if err != nil {
-				errors <- err
-				return
-			}
+		jobs <- datum
+	}
+	close(jobs)
+
+	if workers < 1 {
+		workers = 1
+	}
 
-			artifacts <- a
-		}(datum)
+	if workers > len(data) {
+		workers = len(data)
 	}
 
-	// pile 'em up
-	result := newArtifactSet()
-	for i := 0; i < len(data); i++ {
+	// search for the artifacts in each element of data
+	for i := 0; i < workers; i++ {
+		go func() {
+			for datum := range jobs {
+				if ctx.Err() != nil { // the search is over; drain the queue
+					errors <- ctx.Err()
+					continue
+				}
+
+				errors <- query(ctx, datum, func(artifacts []*Artifact) error {
+					select {
+					case batches <- artifacts:
+						return nil
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+				})
+			}
+		}()
+	}
+
+	// pass 'em along until every query is done
+	failures := &MultiError{}
+	for remaining := len(data); remaining > 0; {
 		select {
-		case a := <-artifacts:
-			result.add(a)
+		case artifacts := <-batches:
+			if err := emit(artifacts); err != nil {
+				return err
+			}
 		case err := <-errors:
-			return nil, err
+			remaining--
+
+			switch {
+			case err == nil:
+			case !bestEffort || ctx.Err() != nil:
+				return err
+			default:
+				failures.add(err)
+			}
 		}
 	}
 
-	return result.data, nil
+	if len(failures.Errors) != 0 {
+		return failures
+	}
+
+	return nil
 }
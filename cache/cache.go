@@ -0,0 +1,253 @@
+/*
+Package cache provides a nexus.Client which caches the results of another, for
+tools which keep asking Nexus the same things.
+
+Results are kept in a Store for a given time to live, set per method. Nexus may
+also be asked if a response is still good with If-None-Match and
+If-Modified-Since, by setting a Transport in the wrapped client's
+http.Client. This package doesn't know when Nexus' contents change, so callers
+which change them (e.g. with Nexus2x.Deploy or Nexus2x.Delete) should
+invalidate what's affected.
+*/
+package cache // import "sbrubbles.org/go/nexus/cache"
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"sbrubbles.org/go/nexus"
+	"sbrubbles.org/go/nexus/search"
+)
+
+// TTLs says for how long the results of each method are kept. Zero means the
+// results of that method aren't cached at all.
+type TTLs struct {
+	Artifacts    time.Duration // e.g. time.Minute
+	Repositories time.Duration // e.g. time.Hour
+	InfoOf       time.Duration // e.g. 10 * time.Minute
+}
+
+// Client caches the results of another nexus.Client. It implements the
+// nexus.Client interface. Errors aren't cached.
+type Client struct {
+	client nexus.Client
+	store  Store
+	ttls   TTLs
+	now    func() time.Time // here for the tests
+}
+
+// New wraps the given client, keeping the results in the given store (a
+// cache.NewLRU(1000) if nil) for the given TTLs.
+func New(client nexus.Client, store Store, ttls TTLs) *Client {
+	if store == nil {
+		store = NewLRU(1000)
+	}
+
+	return &Client{client: client, store: store, ttls: ttls, now: time.Now}
+}
+
+// what's actually stored.
+type entry struct {
+	value   interface{}
+	expires time.Time
+}
+
+// the cached value under key, if there's one and it hasn't expired.
+func (c *Client) get(key string) (interface{}, bool) {
+	value, ok := c.store.Get(key)
+	if !ok {
+		return nil, false
+	}
+
+	e, ok := value.(entry)
+	if !ok || !c.now().Before(e.expires) {
+		c.store.Delete(key)
+		return nil, false
+	}
+
+	return e.value, true
+}
+
+// stores value under key for ttl, if ttl > 0.
+func (c *Client) set(key string, value interface{}, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+
+	c.store.Set(key, entry{value: value, expires: c.now().Add(ttl)})
+}
+
+// the same criteria always gets the same key, no matter the map order. The
+// composite ones without parameters (e.g. search.Or) are told apart by their
+// String().
+func artifactsKey(criteria search.Criteria) string {
+	params := search.OrZero(criteria).Parameters()
+	if params == nil {
+		return fmt.Sprintf("artifacts:%v", criteria)
+	}
+
+	pairs := make([]string, 0, len(params))
+	for k, v := range params {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+
+	return "artifacts?" + strings.Join(pairs, "&")
+}
+
+// if the results of a search with the given criteria may be cached. Their key
+// comes from their String(), so a search filtered with a predicate which
+// isn't a fmt.Stringer (e.g. a search.PredicateFunc) can't be told apart from
+// another, and isn't cached.
+func cacheable(criteria search.Criteria) bool {
+	switch criteria := criteria.(type) {
+	case search.And:
+		return allCacheable(criteria)
+	case search.Or:
+		return allCacheable(criteria)
+	case search.Not:
+		return cacheable(criteria.Criteria)
+	case search.InRepository:
+		return cacheable(criteria.Criteria)
+	case search.Filtered:
+		return cacheable(criteria.Criteria) && describable(criteria.Predicate)
+	}
+
+	return true
+}
+
+func allCacheable(criteria []search.Criteria) bool {
+	for _, c := range criteria {
+		if !cacheable(c) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// if the given predicate, and the ones it's made of, have a String().
+func describable(predicate search.Predicate) bool {
+	var predicates []search.Predicate
+
+	switch p := predicate.(type) {
+	case nil:
+		return true
+	case search.AllOf:
+		predicates = p
+	case search.AnyOf:
+		predicates = p
+	case search.NoneOf:
+		predicates = p
+	default:
+		_, ok := p.(fmt.Stringer)
+		return ok
+	}
+
+	for _, p := range predicates {
+		if !describable(p) {
+			return false
+		}
+	}
+
+	return true
+}
+
+const repositoriesKey = "repositories"
+
+func infoOfKey(artifact *nexus.Artifact) string {
+	return "infoOf:" + artifact.String()
+}
+
+// Artifacts implements the nexus.Client interface.
+func (c *Client) Artifacts(criteria search.Criteria) ([]*nexus.Artifact, error) {
+	return c.ArtifactsContext(context.Background(), criteria)
+}
+
+// ArtifactsContext implements the nexus.Client interface.
+func (c *Client) ArtifactsContext(ctx context.Context, criteria search.Criteria) ([]*nexus.Artifact, error) {
+	if !cacheable(criteria) {
+		return c.client.ArtifactsContext(ctx, criteria)
+	}
+
+	key := artifactsKey(criteria)
+	if value, ok := c.get(key); ok {
+		return append([]*nexus.Artifact{}, value.([]*nexus.Artifact)...), nil
+	}
+
+	artifacts, err := c.client.ArtifactsContext(ctx, criteria)
+	if err != nil {
+		return artifacts, err
+	}
+
+	c.set(key, artifacts, c.ttls.Artifacts)
+	return append([]*nexus.Artifact{}, artifacts...), nil
+}
+
+// Repositories implements the nexus.Client interface.
+func (c *Client) Repositories() ([]*nexus.Repository, error) {
+	return c.RepositoriesContext(context.Background())
+}
+
+// RepositoriesContext implements the nexus.Client interface.
+func (c *Client) RepositoriesContext(ctx context.Context) ([]*nexus.Repository, error) {
+	if value, ok := c.get(repositoriesKey); ok {
+		return append([]*nexus.Repository{}, value.([]*nexus.Repository)...), nil
+	}
+
+	repos, err := c.client.RepositoriesContext(ctx)
+	if err != nil {
+		return repos, err
+	}
+
+	c.set(repositoriesKey, repos, c.ttls.Repositories)
+	return append([]*nexus.Repository{}, repos...), nil
+}
+
+// InfoOf implements the nexus.Client interface.
+func (c *Client) InfoOf(artifact *nexus.Artifact) (*nexus.ArtifactInfo, error) {
+	return c.InfoOfContext(context.Background(), artifact)
+}
+
+// InfoOfContext implements the nexus.Client interface.
+func (c *Client) InfoOfContext(ctx context.Context, artifact *nexus.Artifact) (*nexus.ArtifactInfo, error) {
+	key := infoOfKey(artifact)
+	if value, ok := c.get(key); ok {
+		info := *value.(*nexus.ArtifactInfo)
+		return &info, nil
+	}
+
+	info, err := c.client.InfoOfContext(ctx, artifact)
+	if err != nil {
+		return info, err
+	}
+
+	c.set(key, info, c.ttls.InfoOf)
+
+	result := *info
+	return &result, nil
+}
+
+// Invalidate drops everything in the cache.
+func (c *Client) Invalidate() {
+	c.store.Purge()
+}
+
+// InvalidateArtifacts drops the cached results of a search with the given
+// criteria.
+func (c *Client) InvalidateArtifacts(criteria search.Criteria) {
+	c.store.Delete(artifactsKey(criteria))
+}
+
+// InvalidateRepositories drops the cached repositories.
+func (c *Client) InvalidateRepositories() {
+	c.store.Delete(repositoriesKey)
+}
+
+// InvalidateInfoOf drops the cached information about the given artifact.
+func (c *Client) InvalidateInfoOf(artifact *nexus.Artifact) {
+	c.store.Delete(infoOfKey(artifact))
+}
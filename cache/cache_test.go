@@ -0,0 +1,167 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"sbrubbles.org/go/nexus"
+	"sbrubbles.org/go/nexus/search"
+)
+
+// counts the calls, returning always the same things.
+type countingClient struct {
+	calls map[string]int
+	err   error
+}
+
+func (c *countingClient) Artifacts(criteria search.Criteria) ([]*nexus.Artifact, error) {
+	return c.ArtifactsContext(context.Background(), criteria)
+}
+
+func (c *countingClient) ArtifactsContext(ctx context.Context, criteria search.Criteria) ([]*nexus.Artifact, error) {
+	c.calls["Artifacts"]++
+	return []*nexus.Artifact{{GroupID: "g"}}, c.err
+}
+
+func (c *countingClient) Repositories() ([]*nexus.Repository, error) {
+	return c.RepositoriesContext(context.Background())
+}
+
+func (c *countingClient) RepositoriesContext(ctx context.Context) ([]*nexus.Repository, error) {
+	c.calls["Repositories"]++
+	return []*nexus.Repository{{ID: "releases"}}, c.err
+}
+
+func (c *countingClient) InfoOf(artifact *nexus.Artifact) (*nexus.ArtifactInfo, error) {
+	return c.InfoOfContext(context.Background(), artifact)
+}
+
+func (c *countingClient) InfoOfContext(ctx context.Context, artifact *nexus.Artifact) (*nexus.ArtifactInfo, error) {
+	c.calls["InfoOf"]++
+	return &nexus.ArtifactInfo{Artifact: artifact, Sha1: "abc"}, c.err
+}
+
+func newCounting() *countingClient {
+	return &countingClient{calls: map[string]int{}}
+}
+
+func TestClientImplementsNexusClient(t *testing.T) {
+	if _, ok := interface{}(&Client{}).(nexus.Client); !ok {
+		t.Errorf("*cache.Client does not implement nexus.Client!")
+	}
+}
+
+func TestClientKeepsTheResultsForTheirTTL(t *testing.T) {
+	now := time.Now()
+	inner := newCounting()
+	c := New(inner, nil, TTLs{Artifacts: time.Minute, Repositories: time.Hour, InfoOf: time.Minute})
+	c.now = func() time.Time { return now }
+
+	artifact := &nexus.Artifact{GroupID: "g", ArtifactID: "a", Version: "1"}
+	for i := 0; i < 3; i++ {
+		c.Artifacts(search.ByCoordinates{GroupID: "g", ArtifactID: "a"})
+		c.Repositories()
+		c.InfoOf(artifact)
+	}
+
+	now = now.Add(2 * time.Minute) // only the repositories are still good
+	c.Artifacts(search.ByCoordinates{GroupID: "g", ArtifactID: "a"})
+	c.Repositories()
+	c.InfoOf(artifact)
+
+	expected := map[string]int{"Artifacts": 2, "Repositories": 1, "InfoOf": 2}
+	for method, calls := range expected {
+		if inner.calls[method] != calls {
+			t.Errorf("Expected %v call(s) to %v, got %v", calls, method, inner.calls[method])
+		}
+	}
+}
+
+func TestClientDoesntCacheWithoutATTL(t *testing.T) {
+	inner := newCounting()
+	c := New(inner, nil, TTLs{})
+
+	c.Repositories()
+	c.Repositories()
+
+	if inner.calls["Repositories"] != 2 {
+		t.Errorf("Expected 2 calls, got %v", inner.calls["Repositories"])
+	}
+}
+
+func TestClientDoesntCacheErrors(t *testing.T) {
+	inner := newCounting()
+	inner.err = errors.New("boom")
+	c := New(inner, nil, TTLs{Repositories: time.Hour})
+
+	c.Repositories()
+	c.Repositories()
+
+	if inner.calls["Repositories"] != 2 {
+		t.Errorf("Expected 2 calls, got %v", inner.calls["Repositories"])
+	}
+}
+
+func TestInvalidateDropsTheCachedResults(t *testing.T) {
+	inner := newCounting()
+	c := New(inner, nil, TTLs{Artifacts: time.Hour, Repositories: time.Hour})
+
+	criteria := search.ByRepository("releases")
+	c.Artifacts(criteria)
+	c.Repositories()
+
+	c.InvalidateArtifacts(criteria)
+	c.Artifacts(criteria)
+	c.Repositories()
+
+	c.Invalidate()
+	c.Repositories()
+
+	if inner.calls["Artifacts"] != 2 || inner.calls["Repositories"] != 2 {
+		t.Errorf("Unexpected calls: %v", inner.calls)
+	}
+}
+
+func TestCompositeCriteriaGetTheirOwnKeys(t *testing.T) {
+	inner := newCounting()
+	c := New(inner, nil, TTLs{Artifacts: time.Hour})
+
+	orGroups := search.Or{search.ByCoordinates{GroupID: "org.foo*"}, search.ByCoordinates{GroupID: "org.bar*"}}
+	for _, criteria := range []search.Criteria{
+		search.All,
+		orGroups,
+		search.Or{search.ByCoordinates{GroupID: "org.foo*"}, search.ByCoordinates{GroupID: "org.baz*"}},
+		search.Not{Criteria: search.ByKeyword("x")},
+		orGroups,
+	} {
+		c.Artifacts(criteria)
+	}
+
+	if inner.calls["Artifacts"] != 4 {
+		t.Errorf("Expected 4 calls, got %v", inner.calls["Artifacts"])
+	}
+}
+
+func TestSearchesFilteredWithFunctionsArentCached(t *testing.T) {
+	inner := newCounting()
+	c := New(inner, nil, TTLs{Artifacts: time.Hour})
+
+	described := search.Filtered{Criteria: search.ByKeyword("x"), Predicate: search.ExtensionIn{"jar"}}
+	opaque := search.Filtered{
+		Criteria: search.ByKeyword("x"),
+		Predicate: search.AllOf{search.PredicateFunc(func(artifact *nexus.Artifact) bool {
+			return artifact.Version != ""
+		})},
+	}
+
+	for i := 0; i < 2; i++ {
+		c.Artifacts(described)
+		c.Artifacts(search.Or{opaque, search.ByKeyword("y")})
+	}
+
+	if inner.calls["Artifacts"] != 3 {
+		t.Errorf("Expected 3 calls, got %v", inner.calls["Artifacts"])
+	}
+}
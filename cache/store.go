@@ -0,0 +1,114 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// Store holds the cached values. Implementations must be safe for concurrent
+// use. Expiration is handled by the caller, so a Store only needs to keep
+// things around (or not; a Store may drop whatever it wants, whenever it
+// wants).
+type Store interface {
+	// Returns the value under key, if there's one.
+	Get(key string) (value interface{}, ok bool)
+
+	// Puts value under key, replacing whatever was there.
+	Set(key string, value interface{})
+
+	// Removes the value under key, if there's one.
+	Delete(key string)
+
+	// Removes every value.
+	Purge()
+}
+
+// LRU is an in-memory Store which holds at most a given number of values,
+// dropping the least recently used ones to make room. It implements the
+// cache.Store interface.
+type LRU struct {
+	mutex sync.Mutex
+	size  int
+	order *list.List               // the most recently used up front
+	items map[string]*list.Element // holds *lruItem
+}
+
+type lruItem struct {
+	key   string
+	value interface{}
+}
+
+// NewLRU creates an LRU holding at most size values. A size < 1 is taken as 1.
+func NewLRU(size int) *LRU {
+	if size < 1 {
+		size = 1
+	}
+
+	return &LRU{
+		size:  size,
+		order: list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+// Get implements the cache.Store interface.
+func (lru *LRU) Get(key string) (interface{}, bool) {
+	lru.mutex.Lock()
+	defer lru.mutex.Unlock()
+
+	elem, ok := lru.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	lru.order.MoveToFront(elem)
+	return elem.Value.(*lruItem).value, true
+}
+
+// Set implements the cache.Store interface.
+func (lru *LRU) Set(key string, value interface{}) {
+	lru.mutex.Lock()
+	defer lru.mutex.Unlock()
+
+	if elem, ok := lru.items[key]; ok {
+		elem.Value.(*lruItem).value = value
+		lru.order.MoveToFront(elem)
+		return
+	}
+
+	lru.items[key] = lru.order.PushFront(&lruItem{key, value})
+
+	for lru.order.Len() > lru.size {
+		oldest := lru.order.Back()
+		lru.order.Remove(oldest)
+		delete(lru.items, oldest.Value.(*lruItem).key)
+	}
+}
+
+// Delete implements the cache.Store interface.
+func (lru *LRU) Delete(key string) {
+	lru.mutex.Lock()
+	defer lru.mutex.Unlock()
+
+	if elem, ok := lru.items[key]; ok {
+		lru.order.Remove(elem)
+		delete(lru.items, key)
+	}
+}
+
+// Purge implements the cache.Store interface.
+func (lru *LRU) Purge() {
+	lru.mutex.Lock()
+	defer lru.mutex.Unlock()
+
+	lru.order.Init()
+	lru.items = make(map[string]*list.Element)
+}
+
+// Len returns how many values are in the LRU.
+func (lru *LRU) Len() int {
+	lru.mutex.Lock()
+	defer lru.mutex.Unlock()
+
+	return lru.order.Len()
+}
@@ -0,0 +1,47 @@
+package cache_test
+
+import (
+	"testing"
+
+	"sbrubbles.org/go/nexus/cache"
+)
+
+func TestLRUImplementsStore(t *testing.T) {
+	if _, ok := interface{}(cache.NewLRU(1)).(cache.Store); !ok {
+		t.Errorf("*cache.LRU does not implement cache.Store!")
+	}
+}
+
+func TestLRUDropsTheLeastRecentlyUsed(t *testing.T) {
+	lru := cache.NewLRU(2)
+
+	lru.Set("a", 1)
+	lru.Set("b", 2)
+	lru.Get("a") // now b is the least recently used
+	lru.Set("c", 3)
+
+	if _, ok := lru.Get("b"); ok {
+		t.Errorf("Expected b to be dropped")
+	}
+
+	for _, key := range []string{"a", "c"} {
+		if _, ok := lru.Get(key); !ok {
+			t.Errorf("Expected %v to be kept", key)
+		}
+	}
+
+	if lru.Len() != 2 {
+		t.Errorf("Expected 2 values, got %v", lru.Len())
+	}
+}
+
+func TestLRUPurgeDropsEverything(t *testing.T) {
+	lru := cache.NewLRU(2)
+
+	lru.Set("a", 1)
+	lru.Purge()
+
+	if _, ok := lru.Get("a"); ok || lru.Len() != 0 {
+		t.Errorf("Expected an empty LRU")
+	}
+}
@@ -0,0 +1,163 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+)
+
+// Transport is an http.RoundTripper which remembers the responses to GETs
+// carrying an ETag or a Last-Modified header, and asks the server if they're
+// still good (with If-None-Match and If-Modified-Since) before using them. A
+// 304 Not Modified is then answered with the remembered response. It
+// implements the http.RoundTripper interface.
+//
+// Only bodies up to MaxBodySize are remembered; bigger ones (e.g. artifacts
+// being downloaded) are passed along as they stream in, untouched.
+type Transport struct {
+	Base        http.RoundTripper // e.g. http.DefaultTransport, if nil
+	Store       Store             // where the responses are kept
+	MaxBodySize int64             // in bytes; DefaultMaxBodySize if < 1
+}
+
+// DefaultMaxBodySize is the size of the biggest body a Transport remembers,
+// unless told otherwise: enough for the API's responses, but not for most
+// artifacts.
+const DefaultMaxBodySize = 1 << 20
+
+// NewTransport creates a Transport over base, keeping the responses in store
+// (a cache.NewLRU(1000) if nil).
+func NewTransport(base http.RoundTripper, store Store) *Transport {
+	if store == nil {
+		store = NewLRU(1000)
+	}
+
+	return &Transport{Base: base, Store: store}
+}
+
+// the size of the biggest body this transport remembers.
+func (t *Transport) maxBodySize() int64 {
+	if t.MaxBodySize < 1 {
+		return DefaultMaxBodySize
+	}
+
+	return t.MaxBodySize
+}
+
+// what's remembered of a response.
+type storedResponse struct {
+	status       string
+	statusCode   int
+	header       http.Header
+	body         []byte
+	etag         string
+	lastModified string
+}
+
+// the query is normalized, since the same search may come with its
+// parameters in any order. Different credentials may see different things, so
+// they're part of the key too (hashed, to keep them out of the Store).
+func transportKey(request *http.Request) string {
+	u := *request.URL
+	u.RawQuery = u.Query().Encode()
+
+	auth := sha256.Sum256([]byte(request.Header.Get("Authorization")))
+
+	return "GET " + u.String() + " " + request.Header.Get("Accept") + " " + hex.EncodeToString(auth[:])
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *Transport) RoundTrip(request *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	if request.Method != "GET" {
+		return base.RoundTrip(request)
+	}
+
+	key := transportKey(request)
+
+	value, _ := t.Store.Get(key)
+	stored, cached := value.(*storedResponse)
+	if cached {
+		request = request.Clone(request.Context())
+		if stored.etag != "" {
+			request.Header.Set("If-None-Match", stored.etag)
+		}
+
+		if stored.lastModified != "" {
+			request.Header.Set("If-Modified-Since", stored.lastModified)
+		}
+	}
+
+	response, err := base.RoundTrip(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if cached && response.StatusCode == http.StatusNotModified {
+		response.Body.Close()
+		return stored.responseTo(request), nil
+	}
+
+	etag := response.Header.Get("ETag")
+	lastModified := response.Header.Get("Last-Modified")
+	if response.StatusCode != http.StatusOK || (etag == "" && lastModified == "") {
+		return response, nil // nothing to revalidate with
+	}
+
+	limit := t.maxBodySize()
+	if response.ContentLength > limit {
+		return response, nil // too big to keep
+	}
+
+	// the length may be unknown, so read one byte more than allowed to tell
+	body, err := io.ReadAll(io.LimitReader(response.Body, limit+1))
+	if err != nil {
+		response.Body.Close()
+		return nil, err
+	}
+
+	if int64(len(body)) > limit { // too big after all; hand over what was read and the rest
+		response.Body = readCloser{io.MultiReader(bytes.NewReader(body), response.Body), response.Body}
+		return response, nil
+	}
+	response.Body.Close()
+
+	stored = &storedResponse{
+		status:       response.Status,
+		statusCode:   response.StatusCode,
+		header:       response.Header.Clone(),
+		body:         body,
+		etag:         etag,
+		lastModified: lastModified,
+	}
+	t.Store.Set(key, stored)
+
+	return stored.responseTo(request), nil
+}
+
+// reads from a Reader, but closes a Closer.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// a new response, as if it came from the server.
+func (stored *storedResponse) responseTo(request *http.Request) *http.Response {
+	return &http.Response{
+		Status:        stored.status,
+		StatusCode:    stored.statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        stored.header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(stored.body)),
+		ContentLength: int64(len(stored.body)),
+		Request:       request,
+	}
+}
@@ -0,0 +1,81 @@
+package cache_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"sbrubbles.org/go/nexus/cache"
+)
+
+func TestTransportRevalidatesWithETags(t *testing.T) {
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		hits++
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("the body"))
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: cache.NewTransport(nil, nil)}
+
+	// the same query, in a different order
+	for _, query := range []string{"?a=1&b=2", "?b=2&a=1"} {
+		resp, err := client.Get(server.URL + "/path" + query)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK || string(body) != "the body" {
+			t.Errorf("Unexpected response: %v %q", resp.Status, body)
+		}
+	}
+
+	if hits != 1 {
+		t.Errorf("Expected the body to be sent only once, not %v times", hits)
+	}
+}
+
+func TestTransportPassesBigBodiesAlong(t *testing.T) {
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Header().Set("ETag", `"v1"`)
+		if r.URL.Path == "/unknown-length" {
+			w.(http.Flusher).Flush() // chunked, so there's no Content-Length
+		}
+
+		w.Write([]byte("a body too big to keep"))
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &cache.Transport{Store: cache.NewLRU(10), MaxBodySize: 10}}
+
+	for _, path := range []string{"/known-length", "/known-length", "/unknown-length", "/unknown-length"} {
+		resp, err := client.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if string(body) != "a body too big to keep" {
+			t.Errorf("%v: unexpected body %q", path, body)
+		}
+	}
+
+	// nothing was kept, so nothing was revalidated
+	if hits != 4 {
+		t.Errorf("Expected 4 requests with a body, got %v", hits)
+	}
+}
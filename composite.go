@@ -0,0 +1,289 @@
+package nexus
+
+import (
+	"context"
+	"strconv"
+
+	"sbrubbles.org/go/nexus/search"
+)
+
+// runs a single search with the given parameters, as the clients' own
+// searchArtifacts do.
+type searchFunc func(ctx context.Context, params map[string]string, emit emitFunc) error
+
+// sends to emit the artifacts which satisfy the given criteria. Criteria which
+// compile to parameters go to Nexus as they are, with searchParams. The
+// composite ones (search.And, search.Or, search.Not) which couldn't be pushed
+// down are planned into several searches, run with at most workers goroutines
+// each, and combined here:
+//   - search.Or is the union of its criteria's results, handed over as they
+//     arrive (the repetitions are filtered out downstream);
+//   - search.And merges whatever it can of its criteria into one search, whose
+//     results are handed over if they're in the sets of the other criteria,
+//     and not in the sets of the search.Not ones. The searches for these sets
+//     are narrowed down with the merged criteria, where Nexus allows;
+//   - search.Not on its own is search.And{search.Not{...}}, i.e. a full search
+//     minus the given criteria's;
+//   - search.Filtered applies its predicate to each batch found, as it arrives.
+//     In a search.And, its criteria are merged like the others', and its
+//     predicate applied to the results. search.ByVersionRange is one of these
+//     (see its Filtered method).
+//
+// Only the artifacts' fingerprints are kept in the sets.
+//
+// If bestEffort is true, the searches go on when some of their branches fail,
+// like the split searches do, and the failures are returned at the end in a
+// *MultiError. A search.And whose search.Not sets are incomplete can't tell
+// what to leave out, though, so it hands nothing over.
+func searchCriteria(ctx context.Context, workers int, bestEffort bool, criteria search.Criteria, searchParams searchFunc, emit emitFunc) error {
+	criteria = search.OrZero(criteria)
+	if params := criteria.Parameters(); params != nil {
+		return searchParams(ctx, params, emit)
+	}
+
+	switch criteria := criteria.(type) {
+	case search.Or:
+		return concurrentArtifactSearch(ctx, workers, bestEffort, indicesOf(len(criteria)), func(ctx context.Context, i string, emit emitFunc) error {
+			n, _ := strconv.Atoi(i)
+			return searchCriteria(ctx, workers, bestEffort, criteria[n], searchParams, emit)
+		}, emit)
+	case search.And:
+		return searchIntersection(ctx, workers, bestEffort, criteria, searchParams, emit)
+	case search.Not:
+		return searchIntersection(ctx, workers, bestEffort, search.And{criteria}, searchParams, emit)
+	case search.InRepository:
+		return searchIntersection(ctx, workers, bestEffort, search.And{criteria}, searchParams, emit)
+	case search.Filtered:
+		return searchCriteria(ctx, workers, bestEffort, criteria.Criteria, searchParams, func(artifacts []*Artifact) error {
+			return emit(keep(artifacts, criteria.Predicate))
+		})
+	case search.ByVersionRange:
+		filtered, err := criteria.Filtered()
+		if err != nil {
+			return err
+		}
+
+		return searchCriteria(ctx, workers, bestEffort, filtered, searchParams, emit)
+	}
+
+	// some other criteria without parameters; as before, a full search
+	return searchParams(ctx, map[string]string{}, emit)
+}
+
+// the indices of a slice of the given length, as the data of
+// concurrentArtifactSearch.
+func indicesOf(length int) []string {
+	indices := make([]string, length)
+	for i := range indices {
+		indices[i] = strconv.Itoa(i)
+	}
+
+	return indices
+}
+
+// sends to emit the artifacts which satisfy all of the given criteria.
+func searchIntersection(ctx context.Context, workers int, bestEffort bool, and search.And, searchParams searchFunc, emit emitFunc) error {
+	pushed := search.And{} // the criteria Nexus handles in a single search
+	included := []search.Criteria{}
+	excluded := []search.Criteria{}
+
+	flat, predicates := flatten(and)
+	for _, criteria := range flat {
+		if not, ok := criteria.(search.Not); ok {
+			excluded = append(excluded, not.Criteria)
+			continue
+		}
+
+		if merged := append(pushed[:len(pushed):len(pushed)], criteria); merged.Parameters() != nil {
+			pushed = merged
+			continue
+		}
+
+		included = append(included, criteria)
+	}
+
+	// the search streamed; if nothing was pushed, one of the included ones
+	var streamed search.Criteria = pushed
+	if len(pushed) == 0 && len(included) > 0 {
+		streamed, included = included[0], included[1:]
+	}
+
+	// the other criteria only matter within the pushed ones, so Nexus may
+	// narrow those searches down too
+	for i, criteria := range included {
+		included[i] = narrow(pushed, criteria)
+	}
+
+	for i, criteria := range excluded {
+		excluded[i] = narrow(pushed, criteria)
+	}
+
+	// the sets of the other criteria, each filled by its own search
+	others := append(append([]search.Criteria{}, included...), excluded...)
+	sets := make([]*artifactSet, len(others))
+	failed := make([]bool, len(others)) // each search sets only its own
+	for i := range sets {
+		sets[i] = newArtifactSet()
+	}
+
+	failures := &MultiError{}
+	err := concurrentArtifactSearch(ctx, workers, bestEffort, indicesOf(len(others)), func(ctx context.Context, i string, _ emitFunc) error {
+		n, _ := strconv.Atoi(i)
+		err := searchCriteria(ctx, workers, bestEffort, others[n], searchParams, func(artifacts []*Artifact) error {
+			sets[n].add(artifacts)
+			return nil
+		})
+
+		failed[n] = err != nil
+		return err
+	}, emit)
+	if err != nil {
+		if !addFailures(failures, err) {
+			return err
+		}
+
+		for _, incomplete := range failed[len(included):] {
+			if incomplete { // anything could be in the missing part
+				return failures
+			}
+		}
+	}
+
+	err = searchCriteria(ctx, workers, bestEffort, streamed, searchParams, func(artifacts []*Artifact) error {
+		kept := []*Artifact{}
+		for _, artifact := range artifacts {
+			if inAll(artifact, sets[:len(included)]) && inNone(artifact, sets[len(included):]) &&
+				predicates.Matches(artifact) {
+				kept = append(kept, artifact)
+			}
+		}
+
+		return emit(kept)
+	})
+	if err != nil && !addFailures(failures, err) {
+		return err
+	}
+
+	if len(failures.Errors) != 0 {
+		return failures
+	}
+
+	return nil
+}
+
+// adds err to failures if it's a *MultiError, i.e. the failed branches of a
+// best effort search, returning false otherwise.
+func addFailures(failures *MultiError, err error) bool {
+	multi, ok := err.(*MultiError)
+	if ok {
+		failures.add(multi)
+	}
+
+	return ok
+}
+
+// the given criteria, with the nested search.And (and search.InRepository)
+// ones spread out, and the predicates of the search.Filtered (and
+// search.ByVersionRange) ones apart.
+func flatten(and search.And) ([]search.Criteria, search.AllOf) {
+	flat := []search.Criteria{}
+	predicates := search.AllOf{}
+
+	for _, criteria := range and {
+		var nested search.And
+
+		switch criteria := search.OrZero(criteria).(type) {
+		case search.And:
+			nested = criteria
+		case search.InRepository:
+			if criteria.Parameters() != nil {
+				flat = append(flat, criteria)
+				continue
+			}
+
+			nested = search.And{search.ByRepository(criteria.RepositoryID), criteria.Criteria}
+		case search.ByVersionRange:
+			filtered, err := criteria.Filtered()
+			if err != nil { // it fails when searched
+				flat = append(flat, criteria)
+				continue
+			}
+
+			predicates = append(predicates, filtered.Predicate)
+			nested = search.And{filtered.Criteria}
+		case search.Filtered:
+			if criteria.Predicate != nil {
+				predicates = append(predicates, criteria.Predicate)
+			}
+
+			nested = search.And{criteria.Criteria}
+		default:
+			flat = append(flat, criteria)
+			continue
+		}
+
+		nestedFlat, nestedPredicates := flatten(nested)
+		flat = append(flat, nestedFlat...)
+		predicates = append(predicates, nestedPredicates...)
+	}
+
+	return flat, predicates
+}
+
+// the given criteria, merged with the pushed ones when possible. A search.Or
+// is narrowed down one criteria at a time.
+func narrow(pushed search.And, criteria search.Criteria) search.Criteria {
+	if len(pushed) == 0 {
+		return criteria
+	}
+
+	if narrowed := append(pushed[:len(pushed):len(pushed)], criteria); narrowed.Parameters() != nil {
+		return narrowed
+	}
+
+	if or, ok := criteria.(search.Or); ok {
+		narrowed := make(search.Or, len(or))
+		for i, each := range or {
+			narrowed[i] = narrow(pushed, search.OrZero(each))
+		}
+
+		return narrowed
+	}
+
+	return criteria
+}
+
+// if the given artifact is in all of the given sets.
+func inAll(artifact *Artifact, sets []*artifactSet) bool {
+	for _, set := range sets {
+		if !set.contains(artifact) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// if the given artifact is in none of the given sets.
+func inNone(artifact *Artifact, sets []*artifactSet) bool {
+	for _, set := range sets {
+		if set.contains(artifact) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// the given artifacts which match the given predicate, in a new slice; all of
+// them, if there's no predicate.
+func keep(artifacts []*Artifact, predicate search.Predicate) []*Artifact {
+	kept := make([]*Artifact, 0, len(artifacts))
+	for _, artifact := range artifacts {
+		if predicate == nil || predicate.Matches(artifact) {
+			kept = append(kept, artifact)
+		}
+	}
+
+	return kept
+}
@@ -0,0 +1,247 @@
+package nexus_test
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"sbrubbles.org/go/nexus"
+	"sbrubbles.org/go/nexus/credentials"
+	"sbrubbles.org/go/nexus/nexustest"
+	"sbrubbles.org/go/nexus/search"
+)
+
+// records the query of every lucene search.
+type queryRecorder struct {
+	mutex   sync.Mutex
+	queries []string
+}
+
+func (r *queryRecorder) RoundTrip(request *http.Request) (*http.Response, error) {
+	if strings.HasSuffix(request.URL.Path, "/lucene/search") {
+		query := request.URL.Query()
+		query.Del("from")
+		query.Del("count")
+
+		r.mutex.Lock()
+		r.queries = append(r.queries, query.Encode())
+		r.mutex.Unlock()
+	}
+
+	return http.DefaultTransport.RoundTrip(request)
+}
+
+// the recorded queries, sorted and without repetitions (i.e. the pages).
+func (r *queryRecorder) distinct() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	seen := map[string]bool{}
+	queries := []string{}
+	for _, query := range r.queries {
+		if !seen[query] {
+			seen[query] = true
+			queries = append(queries, query)
+		}
+	}
+	sort.Strings(queries)
+
+	return queries
+}
+
+var compositeSearches = []struct {
+	criteria search.Criteria
+	expected []string
+	queries  []string // the lucene searches; nil if there's a full or repository-wide one
+}{
+	{
+		search.Or{search.ByCoordinates{GroupID: "org.a*"}, search.ByCoordinates{GroupID: "org.b.*"}},
+		[]string{
+			"org.a:x:jar:1@releases",
+			"org.a:y:jar:1@releases",
+			"org.a:z:jar:1@thirdparty",
+			"org.ab:x:jar:1@releases",
+			"org.b.c:x:jar:1@releases",
+			"org.b.c:x:pom:1@releases",
+		},
+		[]string{"g=org.a%2A", "g=org.b.%2A"},
+	},
+	{
+		search.And{search.ByRepository("thirdparty"), search.ByCoordinates{ArtifactID: "x"}},
+		[]string{"org.b:x:jar:1@thirdparty", "org.b:x:jar:2@thirdparty"},
+		[]string{"a=x&repositoryId=thirdparty"},
+	},
+	{
+		search.And{search.ByCoordinates{GroupID: "org.b*"}, search.Not{Criteria: search.ByCoordinates{Packaging: "pom"}}},
+		[]string{
+			"org.b-c:Y:jar:1@releases",
+			"org.b.c:x:jar:1@releases",
+			"org.b:x:jar:1@thirdparty",
+			"org.b:x:jar:2@thirdparty",
+			"org.b_c:z:jar:1@releases",
+		},
+		[]string{"g=org.b%2A", "g=org.b%2A&p=pom"},
+	},
+	{
+		search.And{search.ByKeyword("z"), search.Or{search.ByRepository("thirdparty"), search.ByCoordinates{GroupID: "org.c"}}},
+		[]string{"org.a:z:jar:1@thirdparty", "org.c:z:jar:1@releases"},
+		nil,
+	},
+	{
+		search.InRepository{
+			RepositoryID: "releases",
+			Criteria: search.And{
+				search.ByCoordinates{ArtifactID: "x"},
+				search.Not{Criteria: search.Or{search.ByCoordinates{GroupID: "org.a*"}, search.ByKeyword("b.c")}},
+			},
+		},
+		[]string{"org0:x:jar:1@releases"},
+		[]string{"a=x&g=org.a%2A&repositoryId=releases", "a=x&repositoryId=releases", "q=b.c"},
+	},
+	{
+		search.Or{},
+		[]string{},
+		[]string{},
+	},
+}
+
+func TestCompositeSearchesCombineSeveralQueries(t *testing.T) {
+	server := nexustest.NewUnstartedServer(splitFixture())
+	server.PageSize = 2
+	server.Quirky = true
+	server.Start()
+	defer server.Close()
+
+	known := []*nexus.Artifact{}
+	for _, artifact := range server.Fixture().Artifacts {
+		known = append(known, artifact.Artifact)
+	}
+	fake := nexustest.NewFakeClient(known, nil, server.Fixture().Repositories)
+
+	for _, test := range compositeSearches {
+		recorder := &queryRecorder{}
+		n := nexus.Nexus2x{URL: server.URL, Credentials: credentials.None, HTTPClient: &http.Client{Transport: recorder}}
+
+		artifacts, err := n.Artifacts(test.criteria)
+		if err != nil {
+			t.Fatalf("%v: unexpected error %v", test.criteria, err)
+		}
+
+		if actual := sortedCoordinates(artifacts); !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%v: expected %v, got %v", test.criteria, test.expected, actual)
+		}
+
+		if queries := recorder.distinct(); test.queries != nil && !reflect.DeepEqual(queries, test.queries) {
+			t.Errorf("%v: expected the searches %v, got %v", test.criteria, test.queries, queries)
+		}
+
+		faked, err := fake.Artifacts(test.criteria)
+		if err != nil {
+			t.Fatalf("%v: unexpected error %v", test.criteria, err)
+		}
+
+		if actual := sortedCoordinates(faked); !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%v: expected %v from the fake client, got %v", test.criteria, test.expected, actual)
+		}
+	}
+}
+
+func TestFilteredSearchesKeepOnlyWhatMatches(t *testing.T) {
+	server := nexustest.NewUnstartedServer(splitFixture())
+	server.PageSize = 2
+	server.Quirky = true
+	server.Start()
+	defer server.Close()
+
+	recorder := &queryRecorder{}
+	n := nexus.Nexus2x{URL: server.URL, Credentials: credentials.None, HTTPClient: &http.Client{Transport: recorder}}
+
+	// the predicates go with the rest of the search
+	criteria := search.And{
+		search.Filtered{
+			Criteria:  search.ByCoordinates{GroupID: "org.b*"},
+			Predicate: search.NoneOf{search.GroupIs("org.b")},
+		},
+		search.ByRepository("releases"),
+		search.Filtered{Predicate: search.ExtensionIn{"jar"}},
+	}
+
+	seen := 0
+	err := n.WalkArtifacts(criteria, func(artifact *nexus.Artifact) error {
+		seen++
+		if artifact.GroupID == "org.b" || artifact.Extension != "jar" || artifact.RepositoryID != "releases" {
+			t.Errorf("Unexpected artifact %v", artifact)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	if seen != 3 {
+		t.Errorf("Expected 3 artifacts, got %v", seen)
+	}
+
+	if queries := recorder.distinct(); !reflect.DeepEqual(queries, []string{"g=org.b%2A&repositoryId=releases"}) {
+		t.Errorf("Expected a single search, got %v", queries)
+	}
+
+	fake := nexustest.NewFakeClient(nil, nil, nil)
+	for _, artifact := range server.Fixture().Artifacts {
+		fake.Known = append(fake.Known, artifact.Artifact)
+	}
+
+	faked, err := fake.Artifacts(criteria)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	expected := []string{"org.b-c:Y:jar:1@releases", "org.b.c:x:jar:1@releases", "org.b_c:z:jar:1@releases"}
+	if actual := sortedCoordinates(faked); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %v from the fake client, got %v", expected, actual)
+	}
+}
+
+func TestSearchByVersionRangeFollowsMavensOrder(t *testing.T) {
+	fixture := &nexustest.Fixture{
+		Repositories: []*nexus.Repository{
+			{ID: "releases", Name: "Releases", Type: "hosted", Format: "maven2", Policy: "RELEASE"},
+		},
+	}
+
+	for _, version := range []string{"1.0", "1.2-rc1", "1.2", "1.9", "1.10", "2.0-alpha-1", "2.0", "2.0.1"} {
+		fixture.Artifacts = append(fixture.Artifacts,
+			nexustest.NewArtifact("com.acme:app:jar:"+version+"@releases", version),
+			nexustest.NewArtifact("com.acme:app-extras:jar:"+version+"@releases", version))
+	}
+
+	server := nexustest.NewServer(fixture)
+	defer server.Close()
+
+	n := nexus.Nexus2x{URL: server.URL, Credentials: credentials.None, HTTPClient: &http.Client{}}
+
+	artifacts, err := n.Artifacts(search.ByVersionRange{GroupID: "com.acme", ArtifactID: "app", Range: "[1.2,2.0),[2.0.1]"})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	expected := []string{
+		"com.acme:app:jar:1.10@releases",
+		"com.acme:app:jar:1.2@releases",
+		"com.acme:app:jar:1.9@releases",
+		"com.acme:app:jar:2.0-alpha-1@releases",
+		"com.acme:app:jar:2.0.1@releases",
+	}
+	if actual := sortedCoordinates(artifacts); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+
+	_, err = n.Artifacts(search.ByVersionRange{GroupID: "com.acme", ArtifactID: "app", Range: "[2.0,1.2)"})
+	if _, ok := err.(*search.RangeError); !ok {
+		t.Errorf("Expected a *search.RangeError, got %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+package nexus
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"sbrubbles.org/go/nexus/search"
+)
+
+// DeleteResult says how the deletion of a single artifact went.
+type DeleteResult struct {
+	Artifact *Artifact // e.g. com.acme:app:jar:1.0-SNAPSHOT@snapshots
+	Err      error     // nil if the artifact was deleted
+}
+
+// Delete removes the given artifact from its (hosted) repository.
+func (nexus Nexus2x) Delete(artifact *Artifact) error {
+	return nexus.DeleteContext(context.Background(), artifact)
+}
+
+// DeleteContext behaves like Delete, but bound to the given context.
+func (nexus Nexus2x) DeleteContext(ctx context.Context, artifact *Artifact) error {
+	// resolve the artifact first, as in InfoOf
+	path, err := nexus.fetchRepositoryPathOf(ctx, artifact)
+	if err != nil {
+		return err
+	}
+
+	return nexus.deleteContent(ctx, artifact.RepositoryID, path)
+}
+
+// DeleteVersion removes the whole version directory (i.e. every artifact with
+// the same GAV, plus the POM and the checksums) of the given artifact from its
+// (hosted) repository. The artifact's classifier and extension are ignored.
+func (nexus Nexus2x) DeleteVersion(artifact *Artifact) error {
+	return nexus.DeleteVersionContext(context.Background(), artifact)
+}
+
+// DeleteVersionContext behaves like DeleteVersion, but bound to the given
+// context.
+func (nexus Nexus2x) DeleteVersionContext(ctx context.Context, artifact *Artifact) error {
+	// a trailing / tells Nexus it's a directory
+	path := "/" + strings.Replace(artifact.GroupID, ".", "/", -1) +
+		"/" + artifact.ArtifactID + "/" + artifact.Version + "/"
+
+	return nexus.deleteContent(ctx, artifact.RepositoryID, path)
+}
+
+// DeleteWhere removes every artifact in the given (hosted) repository which
+// satisfies the given criteria, returning how each deletion went. Nil is the
+// same as search.All, which removes everything in the repository. The error
+// is only non-nil if the search itself fails; deletions go on even if some of
+// them fail.
+//
+// Unlike in a search, where Nexus takes them as prefixes, the group and
+// artifact IDs without an asterisk are matched exactly, so removing com.acme
+// leaves com.acmecorp alone.
+func (nexus Nexus2x) DeleteWhere(repositoryID string, criteria search.Criteria) ([]DeleteResult, error) {
+	return nexus.DeleteWhereContext(context.Background(), repositoryID, criteria)
+}
+
+// DeleteWhereContext behaves like DeleteWhere, but bound to the given context.
+func (nexus Nexus2x) DeleteWhereContext(ctx context.Context, repositoryID string, criteria search.Criteria) ([]DeleteResult, error) {
+	artifacts, err := nexus.ArtifactsContext(ctx,
+		search.InRepository{RepositoryID: repositoryID, Criteria: exactly(search.OrZero(criteria))})
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]DeleteResult, len(artifacts))
+	for i, artifact := range artifacts {
+		results[i] = DeleteResult{Artifact: artifact, Err: nexus.DeleteContext(ctx, artifact)}
+	}
+
+	return results, nil
+}
+
+// the given criteria, with every search.ByCoordinates in it filtered by its
+// group and artifact IDs, if they're exact (i.e. without an asterisk).
+func exactly(criteria search.Criteria) search.Criteria {
+	switch criteria := criteria.(type) {
+	case search.ByCoordinates:
+		predicates := search.AllOf{}
+		if criteria.GroupID != "" && !strings.Contains(criteria.GroupID, "*") {
+			predicates = append(predicates, search.GroupIs(criteria.GroupID))
+		}
+
+		if criteria.ArtifactID != "" && !strings.Contains(criteria.ArtifactID, "*") {
+			predicates = append(predicates, search.ArtifactIs(criteria.ArtifactID))
+		}
+
+		if len(predicates) == 0 {
+			return criteria
+		}
+
+		return search.Filtered{Criteria: criteria, Predicate: predicates}
+	case search.And:
+		exact := make(search.And, len(criteria))
+		for i, each := range criteria {
+			exact[i] = exactly(search.OrZero(each))
+		}
+
+		return exact
+	case search.Or:
+		exact := make(search.Or, len(criteria))
+		for i, each := range criteria {
+			exact[i] = exactly(search.OrZero(each))
+		}
+
+		return exact
+	case search.Not:
+		return search.Not{Criteria: exactly(search.OrZero(criteria.Criteria))}
+	case search.InRepository:
+		return search.InRepository{RepositoryID: criteria.RepositoryID, Criteria: exactly(search.OrZero(criteria.Criteria))}
+	case search.Filtered:
+		return search.Filtered{Criteria: exactly(search.OrZero(criteria.Criteria)), Predicate: criteria.Predicate}
+	}
+
+	return criteria // e.g. search.ByVersionRange, exact already
+}
+
+// DELETEs the given path in the given repository.
+func (nexus Nexus2x) deleteContent(ctx context.Context, repositoryID string, path string) error {
+	resp, err := nexus.send(ctx, "DELETE",
+		"service/local/repositories/"+repositoryID+"/content"+path, nil,
+		nil, http.Header{"Accept": {"application/xml"}})
+	if err != nil {
+		return err
+	}
+
+	resp.Body.Close()
+	return nil
+}
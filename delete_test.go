@@ -0,0 +1,139 @@
+package nexus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"sbrubbles.org/go/nexus/credentials"
+	"sbrubbles.org/go/nexus/search"
+)
+
+func TestDeleteVersionRemovesTheGAVDirectory(t *testing.T) {
+	deleted := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "DELETE" {
+			deleted = r.URL.Path
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	n := New(server.URL, credentials.None).(*Nexus2x)
+	err := n.DeleteVersion(&Artifact{GroupID: "com.acme", ArtifactID: "app", Version: "1.0", Extension: "jar", RepositoryID: "releases"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if deleted != "/service/local/repositories/releases/content/com/acme/app/1.0/" {
+		t.Errorf("Unexpected path deleted: %v", deleted)
+	}
+}
+
+func TestDeleteWhereReportsEachDeletion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/service/local/lucene/search":
+			if r.URL.Query().Get("repositoryId") != "snapshots" {
+				t.Errorf("Expected a search in snapshots, got %v", r.URL)
+			}
+
+			lucenePages(lucenePage("g", "a", "1.0-SNAPSHOT", "snapshots", "jar", "war")).ServeHTTP(w, r)
+		case r.URL.Path == "/service/local/artifact/maven/resolve":
+			e := r.URL.Query().Get("e")
+			w.Write([]byte("<artifact-resolution><data><repositoryPath>/g/a/1.0-SNAPSHOT/a-1.0-SNAPSHOT." + e +
+				"</repositoryPath></data></artifact-resolution>"))
+		case r.Method == "DELETE" && strings.HasSuffix(r.URL.Path, ".war"):
+			http.Error(w, "nope", http.StatusForbidden)
+		case r.Method == "DELETE":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("Unexpected request: %v %v", r.Method, r.URL)
+		}
+	}))
+	defer server.Close()
+
+	n := New(server.URL, credentials.None).(*Nexus2x)
+	results, err := n.DeleteWhere("snapshots", search.ByCoordinates{GroupID: "g"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %v", results)
+	}
+
+	for _, result := range results {
+		failed := result.Err != nil
+		if failed != (result.Artifact.Extension == "war") {
+			t.Errorf("Unexpected result for %v: %v", result.Artifact, result.Err)
+		}
+	}
+}
+
+func TestDeleteWhereLeavesGroupsWithTheSamePrefixAlone(t *testing.T) {
+	var mutex sync.Mutex
+	deleted := []string{}
+
+	// like Nexus, g=com.acme finds com.acmecorp too
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		switch {
+		case r.URL.Path == "/service/local/lucene/search":
+			lucenePages(
+				lucenePage("com.acme", "app", "1.0", "releases", "jar"),
+				lucenePage("com.acmecorp", "app", "1.0", "releases", "jar")).ServeHTTP(w, r)
+		case r.URL.Path == "/service/local/artifact/maven/resolve":
+			w.Write([]byte("<artifact-resolution><data><repositoryPath>/" +
+				strings.Replace(query.Get("g"), ".", "/", -1) + "/app/1.0/app-1.0.jar" +
+				"</repositoryPath></data></artifact-resolution>"))
+		case r.Method == "DELETE":
+			mutex.Lock()
+			deleted = append(deleted, r.URL.Path)
+			mutex.Unlock()
+
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("Unexpected request: %v %v", r.Method, r.URL)
+		}
+	}))
+	defer server.Close()
+
+	n := New(server.URL, credentials.None).(*Nexus2x)
+	results, err := n.DeleteWhere("releases", search.ByCoordinates{GroupID: "com.acme"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(results) != 1 || results[0].Artifact.GroupID != "com.acme" {
+		t.Errorf("Expected only com.acme's artifact, got %v", results)
+	}
+
+	if len(deleted) != 1 || deleted[0] != "/service/local/repositories/releases/content/com/acme/app/1.0/app-1.0.jar" {
+		t.Errorf("Expected only com.acme's artifact deleted, got %v", deleted)
+	}
+}
+
+func TestExactlyFiltersEveryExactCoordinate(t *testing.T) {
+	criteria := exactly(search.And{
+		search.ByCoordinates{GroupID: "com.acme", ArtifactID: "app*"},
+		search.Not{Criteria: search.Or{search.ByCoordinates{ArtifactID: "app-extras"}, search.ByKeyword("acme")}},
+	})
+
+	expected := search.And{
+		search.Filtered{Criteria: search.ByCoordinates{GroupID: "com.acme", ArtifactID: "app*"}, Predicate: search.AllOf{search.GroupIs("com.acme")}},
+		search.Not{Criteria: search.Or{
+			search.Filtered{Criteria: search.ByCoordinates{ArtifactID: "app-extras"}, Predicate: search.AllOf{search.ArtifactIs("app-extras")}},
+			search.ByKeyword("acme"),
+		}},
+	}
+
+	if !reflect.DeepEqual(criteria, expected) {
+		t.Errorf("Expected %v, got %v", expected, criteria)
+	}
+}
@@ -0,0 +1,219 @@
+package nexus
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Attachment is a file deployed along with an artifact, sharing its GAV (e.g.
+// its sources, javadoc, or its POM).
+type Attachment struct {
+	Classifier string    // e.g. sources, javadoc, <the empty string>...
+	Extension  string    // e.g. jar, pom
+	Content    io.Reader // the file's bytes
+}
+
+// DeployRefusedError is returned when a deployment is refused before reaching
+// Nexus, since Nexus would refuse it anyway (e.g. a SNAPSHOT version in a
+// RELEASE policy repository).
+type DeployRefusedError struct {
+	Repository *Repository // where the artifact would go
+	Artifact   *Artifact   // what would be deployed
+	Reason     string      // e.g. SNAPSHOT versions can't go into a RELEASE repository
+}
+
+// Error implements the error interface.
+func (err DeployRefusedError) Error() string {
+	if err.Repository == nil {
+		return fmt.Sprintf("Can't deploy %v: %v", err.Artifact, err.Reason)
+	}
+
+	return fmt.Sprintf("Can't deploy %v to %v: %v", err.Artifact, err.Repository.ID, err.Reason)
+}
+
+// Deploy uploads the given content as the given artifact to a hosted
+// repository, along with the given attachments, in a single request. The
+// artifact's repository ID is ignored; repository says where it goes.
+//
+// The attachments may include the POM (an Attachment with no classifier and
+// the extension 'pom'). If they don't, a minimal one is generated, with the
+// artifact's extension as the packaging.
+//
+// Deploy refuses up front to deploy into a non-hosted repository, or to mix
+// up SNAPSHOT and RELEASE versions and policies, returning a
+// *DeployRefusedError.
+func (nexus Nexus2x) Deploy(repository *Repository, artifact *Artifact, content io.Reader, attachments ...Attachment) error {
+	return nexus.DeployContext(context.Background(), repository, artifact, content, attachments...)
+}
+
+// DeployContext behaves like Deploy, but bound to the given context.
+func (nexus Nexus2x) DeployContext(ctx context.Context, repository *Repository, artifact *Artifact, content io.Reader, attachments ...Attachment) error {
+	if err := checkDeployable(repository, artifact, content, attachments); err != nil {
+		return err
+	}
+
+	// Nexus expects the POM first, and then the files, each one preceded by its
+	// extension and classifier
+	var pom io.Reader
+	files := []Attachment{{artifact.Classifier, artifact.Extension, content}}
+	for _, attachment := range attachments {
+		if attachment.Extension == "pom" && attachment.Classifier == "" {
+			pom = attachment.Content
+			continue
+		}
+
+		files = append(files, attachment)
+	}
+
+	if pom == nil {
+		generated, err := minimalPOMFor(artifact)
+		if err != nil {
+			return err
+		}
+
+		pom = bytes.NewReader(generated)
+	}
+
+	// stream the multipart body, instead of piling everything up in memory
+	reader, writer := io.Pipe()
+	form := multipart.NewWriter(writer)
+
+	go func() {
+		writer.CloseWithError(writeDeployForm(form, repository.ID, pom, files))
+	}()
+
+	resp, err := nexus.send(ctx, "POST", "service/local/artifact/maven/content", nil,
+		reader, http.Header{"Content-Type": {form.FormDataContentType()}})
+	reader.Close() // stops the writer, if it's still going
+	if err != nil {
+		return err
+	}
+
+	resp.Body.Close()
+	return nil
+}
+
+// Nexus would refuse these anyway, so don't even bother sending the bytes.
+// Missing pieces are refused too, since they'd only blow up while the body is
+// being streamed.
+func checkDeployable(repository *Repository, artifact *Artifact, content io.Reader, attachments []Attachment) error {
+	refuse := func(reason string) error {
+		return &DeployRefusedError{Repository: repository, Artifact: artifact, Reason: reason}
+	}
+
+	switch {
+	case repository == nil:
+		return refuse("there's no repository")
+	case artifact == nil:
+		return refuse("there's no artifact")
+	case content == nil:
+		return refuse("there's no content")
+	}
+
+	for _, attachment := range attachments {
+		if attachment.Content == nil {
+			return refuse(fmt.Sprintf("the attachment %v.%v has no content", attachment.Classifier, attachment.Extension))
+		}
+	}
+
+	if artifact.GroupID == "" || artifact.ArtifactID == "" || artifact.Version == "" {
+		return refuse("the group ID, artifact ID and version are all required")
+	}
+
+	if repository.Type != "hosted" {
+		return refuse("only hosted repositories accept deployments")
+	}
+
+	snapshot := isSnapshot(artifact.Version)
+	switch {
+	case snapshot && repository.Policy == "RELEASE":
+		return refuse("SNAPSHOT versions can't go into a RELEASE repository")
+	case !snapshot && repository.Policy == "SNAPSHOT":
+		return refuse("only SNAPSHOT versions can go into a SNAPSHOT repository")
+	}
+
+	return nil
+}
+
+// a snapshot's timestamped version, e.g. 1.0-20200101.123456-1.
+var timestampedRe = regexp.MustCompile(`-\d{8}\.\d{6}-\d+$`)
+
+// whether the given version is a SNAPSHOT, either as X-SNAPSHOT (in any case)
+// or timestamped.
+func isSnapshot(version string) bool {
+	return strings.HasSuffix(strings.ToUpper(version), "-SNAPSHOT") || timestampedRe.MatchString(version)
+}
+
+// writes the form Nexus' upload expects.
+func writeDeployForm(form *multipart.Writer, repositoryID string, pom io.Reader, files []Attachment) error {
+	fields := [][2]string{{"r", repositoryID}, {"hasPom", "true"}}
+	for _, field := range fields {
+		if err := form.WriteField(field[0], field[1]); err != nil {
+			return err
+		}
+	}
+
+	if err := writeDeployFile(form, "pom.xml", pom); err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if err := form.WriteField("e", file.Extension); err != nil {
+			return err
+		}
+
+		if err := form.WriteField("c", file.Classifier); err != nil {
+			return err
+		}
+
+		if err := writeDeployFile(form, "file."+file.Extension, file.Content); err != nil {
+			return err
+		}
+	}
+
+	return form.Close()
+}
+
+func writeDeployFile(form *multipart.Writer, name string, content io.Reader) error {
+	part, err := form.CreateFormFile("file", name)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(part, content)
+	return err
+}
+
+// a POM with just the coordinates, which is enough for Nexus.
+func minimalPOMFor(artifact *Artifact) ([]byte, error) {
+	pom := struct {
+		XMLName      xml.Name `xml:"project"`
+		Xmlns        string   `xml:"xmlns,attr"`
+		ModelVersion string   `xml:"modelVersion"`
+		GroupID      string   `xml:"groupId"`
+		ArtifactID   string   `xml:"artifactId"`
+		Version      string   `xml:"version"`
+		Packaging    string   `xml:"packaging,omitempty"`
+	}{
+		Xmlns:        "http://maven.apache.org/POM/4.0.0",
+		ModelVersion: "4.0.0",
+		GroupID:      artifact.GroupID,
+		ArtifactID:   artifact.ArtifactID,
+		Version:      artifact.Version,
+		Packaging:    artifact.Extension,
+	}
+
+	body, err := xml.MarshalIndent(pom, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), body...), nil
+}
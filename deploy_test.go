@@ -0,0 +1,138 @@
+package nexus
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"sbrubbles.org/go/nexus/credentials"
+)
+
+var releases = &Repository{ID: "releases", Type: "hosted", Format: "maven2", Policy: "RELEASE"}
+
+func TestDeploySendsThePOMAndEveryFileInOrder(t *testing.T) {
+	parts := []string{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/service/local/artifact/maven/content" {
+			t.Errorf("Unexpected request: %v %v", r.Method, r.URL)
+		}
+
+		reader, err := r.MultipartReader()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+
+			content, _ := io.ReadAll(part)
+			if part.FileName() != "" && strings.Contains(string(content), "<project") {
+				parts = append(parts, part.FormName()+"=<pom>")
+			} else {
+				parts = append(parts, part.FormName()+"="+string(content))
+			}
+		}
+
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	n := New(server.URL, credentials.None).(*Nexus2x)
+	err := n.Deploy(releases,
+		&Artifact{GroupID: "g", ArtifactID: "a", Version: "1.0", Extension: "jar"},
+		strings.NewReader("jar bytes"),
+		Attachment{Classifier: "sources", Extension: "jar", Content: strings.NewReader("sources bytes")},
+		Attachment{Classifier: "javadoc", Extension: "jar", Content: strings.NewReader("javadoc bytes")})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := "r=releases hasPom=true file=<pom> " +
+		"e=jar c= file=jar bytes " +
+		"e=jar c=sources file=sources bytes " +
+		"e=jar c=javadoc file=javadoc bytes"
+	if strings.Join(parts, " ") != expected {
+		t.Errorf("Expected %q, got %q", expected, strings.Join(parts, " "))
+	}
+}
+
+func TestMinimalPOMForHasTheCoordinates(t *testing.T) {
+	pom, err := minimalPOMFor(&Artifact{GroupID: "g", ArtifactID: "a", Version: "1.0", Extension: "war"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, s := range []string{"<groupId>g</groupId>", "<artifactId>a</artifactId>", "<version>1.0</version>", "<packaging>war</packaging>"} {
+		if !strings.Contains(string(pom), s) {
+			t.Errorf("Expected %v in %s", s, pom)
+		}
+	}
+}
+
+var checkDeployableErrors = []struct {
+	repository *Repository
+	version    string
+}{
+	{releases, "1.0-SNAPSHOT"},
+	{releases, "1.0-snapshot"},
+	{releases, "1.0-20200101.123456-1"},
+	{&Repository{ID: "snapshots", Type: "hosted", Policy: "SNAPSHOT"}, "1.0"},
+	{&Repository{ID: "snapshots", Type: "hosted", Policy: "SNAPSHOT"}, "1.0-20200101"},
+	{&Repository{ID: "central", Type: "proxy", Policy: "RELEASE"}, "1.0"},
+	{releases, ""},
+}
+
+func TestDeployRefusesUpFront(t *testing.T) {
+	n := Nexus2x{URL: "http://nowhere.invalid", Credentials: credentials.None, HTTPClient: &http.Client{}}
+
+	for _, test := range checkDeployableErrors {
+		err := n.Deploy(test.repository,
+			&Artifact{GroupID: "g", ArtifactID: "a", Version: test.version, Extension: "jar"},
+			strings.NewReader(""))
+
+		if _, ok := err.(*DeployRefusedError); !ok {
+			t.Errorf("Expected a *DeployRefusedError for %v in %v, got %v", test.version, test.repository, err)
+		}
+	}
+}
+
+func TestCheckDeployableTakesEverySnapshotAsOne(t *testing.T) {
+	snapshots := &Repository{ID: "snapshots", Type: "hosted", Policy: "SNAPSHOT"}
+
+	for _, version := range []string{"1.0-SNAPSHOT", "1.0-snapshot", "1.0-20200101.123456-1"} {
+		artifact := &Artifact{GroupID: "g", ArtifactID: "a", Version: version, Extension: "jar"}
+		if err := checkDeployable(snapshots, artifact, strings.NewReader(""), nil); err != nil {
+			t.Errorf("Expected %v to go into %v, got %v", version, snapshots.ID, err)
+		}
+	}
+}
+
+func TestDeployRefusesWhatsMissing(t *testing.T) {
+	n := Nexus2x{URL: "http://nowhere.invalid", Credentials: credentials.None, HTTPClient: &http.Client{}}
+	artifact := &Artifact{GroupID: "g", ArtifactID: "a", Version: "1.0", Extension: "jar"}
+
+	errs := []error{
+		n.Deploy(nil, artifact, strings.NewReader("")),
+		n.Deploy(releases, nil, strings.NewReader("")),
+		n.Deploy(releases, artifact, nil),
+		n.Deploy(releases, artifact, strings.NewReader(""), Attachment{Classifier: "sources", Extension: "jar"}),
+	}
+
+	for i, err := range errs {
+		refused, ok := err.(*DeployRefusedError)
+		if !ok {
+			t.Errorf("%v: expected a *DeployRefusedError, got %v", i, err)
+			continue
+		}
+
+		if refused.Error() == "" {
+			t.Errorf("%v: expected a message", i)
+		}
+	}
+}
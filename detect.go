@@ -0,0 +1,181 @@
+package nexus
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"sbrubbles.org/go/nexus/credentials"
+	"sbrubbles.org/go/nexus/util"
+)
+
+// UnsupportedServerError is returned by Detect when the server answered, but
+// isn't a Nexus version this package has a Client for. Version and Edition
+// are empty if the server didn't say.
+type UnsupportedServerError struct {
+	URL     string // e.g. http://somewhere.com:8080/nexus
+	Version string // e.g. 1.9.2
+	Edition string // e.g. OSS, PRO
+}
+
+// Error implements the error interface.
+func (err UnsupportedServerError) Error() string {
+	if err.Version == "" {
+		return fmt.Sprintf("%v doesn't look like a supported Nexus", err.URL)
+	}
+
+	return fmt.Sprintf("Nexus %v %v at %v isn't supported", err.Version, err.Edition, err.URL)
+}
+
+// UnreachableError is returned by Detect when the server couldn't be reached
+// at all.
+type UnreachableError struct {
+	URL string // e.g. http://somewhere.com:8080/nexus
+	Err error  // what went wrong
+}
+
+// Error implements the error interface.
+func (err UnreachableError) Error() string {
+	return fmt.Sprintf("Can't reach %v: %v", err.URL, err.Err)
+}
+
+// Unwrap returns the underlying error.
+func (err UnreachableError) Unwrap() error {
+	return err.Err
+}
+
+// Detect asks the Nexus at url which version it is, and returns the matching
+// Client. It returns an *UnreachableError if the server can't be reached, and
+// an *UnsupportedServerError if it isn't a Nexus 2.x or 3.x.
+func Detect(url string, c credentials.Credentials) (Client, error) {
+	return DetectContext(context.Background(), url, c)
+}
+
+// DetectContext behaves like Detect, but bound to the given context.
+func DetectContext(ctx context.Context, url string, c credentials.Credentials) (Client, error) {
+	c = credentials.OrZero(c)
+	client := &http.Client{}
+
+	// Nexus 2 first, since it says more about itself
+	version, edition, err2 := probeNexus2(ctx, client, url, c)
+	if err2 == nil && version != "" {
+		if !strings.HasPrefix(version, "2.") {
+			return nil, &UnsupportedServerError{URL: url, Version: version, Edition: edition}
+		}
+
+		return &Nexus2x{URL: url, Credentials: c, HTTPClient: client}, nil
+	}
+
+	if _, ok := err2.(*credentials.Error); ok {
+		return nil, err2
+	}
+
+	version, edition, err3 := probeNexus3(ctx, client, url, c)
+	if err3 == nil {
+		if version != "" && !strings.HasPrefix(version, "3.") {
+			return nil, &UnsupportedServerError{URL: url, Version: version, Edition: edition}
+		}
+
+		return &Nexus3x{URL: url, Credentials: c, HTTPClient: client}, nil
+	}
+
+	switch err3.(type) {
+	case *credentials.Error, *UnsupportedServerError:
+		return nil, err3
+	case Error: // the server answered, just not what we expected
+		return nil, &UnsupportedServerError{URL: url}
+	}
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	return nil, &UnreachableError{URL: url, Err: err3}
+}
+
+// GETs path in url, returning the response if everything went well.
+func probe(ctx context.Context, client *http.Client, url string, path string, c credentials.Credentials) (*http.Response, error) {
+	fullURL, err := util.BuildFullURL(url, path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	get, err := http.NewRequestWithContext(ctx, "GET", fullURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Sign(get)
+	get.Header.Add("Accept", "application/xml")
+
+	response, err := client.Do(get)
+	if err != nil {
+		return nil, err
+	}
+
+	status := response.StatusCode
+	switch {
+	case status == http.StatusUnauthorized:
+		response.Body.Close()
+		return nil, &credentials.Error{URL: fullURL, Credentials: c}
+	case 400 <= status && status < 600:
+		return nil, errorFromResponse(url, response)
+	}
+
+	return response, nil
+}
+
+// Nexus 2 tells its version and edition in its status.
+func probeNexus2(ctx context.Context, client *http.Client, url string, c credentials.Credentials) (version string, edition string, err error) {
+	response, err := probe(ctx, client, url, "service/local/status", c)
+	if err != nil {
+		return "", "", err
+	}
+
+	defer response.Body.Close()
+
+	var payload struct {
+		Data struct {
+			Version string `xml:"version"`
+			Edition string `xml:"editionShort"`
+		} `xml:"data"`
+	}
+
+	if err := xml.NewDecoder(response.Body).Decode(&payload); err != nil {
+		return "", "", err
+	}
+
+	return payload.Data.Version, payload.Data.Edition, nil
+}
+
+// e.g. Nexus/3.37.3-02 (OSS)
+var serverRe = regexp.MustCompile(`^Nexus/(\S+)(?: \(([^)]*)\))?`)
+
+// Nexus 3's status has no body; the version and edition are in the Server
+// header, if the server is configured to send it. A server which sends
+// neither the header nor an empty body isn't taken as a Nexus 3.
+func probeNexus3(ctx context.Context, client *http.Client, url string, c credentials.Credentials) (version string, edition string, err error) {
+	response, err := probe(ctx, client, url, "service/rest/v1/status", c)
+	if err != nil {
+		return "", "", err
+	}
+
+	body, err := bodyToBytes(response.Body)
+	if err != nil {
+		return "", "", err
+	}
+
+	matches := serverRe.FindStringSubmatch(response.Header.Get("Server"))
+	if matches == nil {
+		if len(body) != 0 {
+			return "", "", &UnsupportedServerError{URL: url}
+		}
+
+		return "", "", nil
+	}
+
+	return matches[1], matches[2], nil
+}
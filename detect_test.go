@@ -0,0 +1,92 @@
+package nexus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"sbrubbles.org/go/nexus/credentials"
+)
+
+func TestDetectFindsNexus2(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/service/local/status" {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Write([]byte("<status><data><version>2.14.20-02</version><editionShort>OSS</editionShort></data></status>"))
+	}))
+	defer server.Close()
+
+	client, err := Detect(server.URL, credentials.None)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if _, ok := client.(*Nexus2x); !ok {
+		t.Errorf("Expected a *Nexus2x, got %T", client)
+	}
+}
+
+func TestDetectFindsNexus3(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/service/rest/v1/status" {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Server", "Nexus/3.37.3-02 (OSS)")
+	}))
+	defer server.Close()
+
+	client, err := Detect(server.URL, credentials.None)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if _, ok := client.(*Nexus3x); !ok {
+		t.Errorf("Expected a *Nexus3x, got %T", client)
+	}
+}
+
+func TestDetectRefusesUnsupportedVersions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<status><data><version>1.9.2</version><editionShort>OSS</editionShort></data></status>"))
+	}))
+	defer server.Close()
+
+	_, err := Detect(server.URL, credentials.None)
+
+	e, ok := err.(*UnsupportedServerError)
+	if !ok {
+		t.Fatalf("Expected an *UnsupportedServerError, got %v", err)
+	}
+
+	if e.Version != "1.9.2" || e.Edition != "OSS" {
+		t.Errorf("Unexpected version and edition in %v", e)
+	}
+}
+
+func TestDetectRefusesServersWhichArentNexus(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	if _, err := Detect(server.URL, credentials.None); err == nil {
+		t.Errorf("Expected an error!")
+	} else if _, ok := err.(*UnsupportedServerError); !ok {
+		t.Errorf("Expected an *UnsupportedServerError, got %v", err)
+	}
+}
+
+func TestDetectReportsUnreachableServers(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close() // nobody's home
+
+	if _, err := Detect(url, credentials.None); err == nil {
+		t.Errorf("Expected an error!")
+	} else if _, ok := err.(*UnreachableError); !ok {
+		t.Errorf("Expected an *UnreachableError, got %v", err)
+	}
+}
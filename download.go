@@ -0,0 +1,113 @@
+package nexus
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ChecksumError is returned when the bytes downloaded for an artifact don't
+// match the SHA1 Nexus reports for it.
+type ChecksumError struct {
+	Artifact *Artifact // e.g. org.springframework:spring-core:jar:4.1.3.RELEASE@central
+	Expected string    // the SHA1 Nexus reports
+	Actual   string    // the SHA1 of the bytes downloaded
+}
+
+// Error implements the error interface.
+func (err ChecksumError) Error() string {
+	return fmt.Sprintf("Checksum mismatch for %v: expected SHA1 %v, got %v",
+		err.Artifact, err.Expected, err.Actual)
+}
+
+// Download writes the contents of the given artifact to w, checking them
+// against the SHA1 Nexus reports while streaming. On a mismatch it returns a
+// *ChecksumError; since the check can only be done at the end, w will have
+// received the (bad) bytes by then. If Nexus reports no SHA1, there's nothing
+// to check against.
+func (nexus Nexus2x) Download(artifact *Artifact, w io.Writer) error {
+	return nexus.DownloadContext(context.Background(), artifact, w)
+}
+
+// DownloadContext behaves like Download, but bound to the given context.
+func (nexus Nexus2x) DownloadContext(ctx context.Context, artifact *Artifact, w io.Writer) error {
+	_, err := nexus.download(ctx, artifact, w)
+	return err
+}
+
+// does the actual downloading, returning the artifact's repository path.
+func (nexus Nexus2x) download(ctx context.Context, artifact *Artifact, w io.Writer) (string, error) {
+	// resolve the artifact first, as in InfoOf
+	path, err := nexus.fetchRepositoryPathOf(ctx, artifact)
+	if err != nil {
+		return "", err
+	}
+
+	// the SHA1 to check against
+	info, err := nexus.fetchInfoAt(ctx, artifact, path)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := nexus.send(ctx, "GET",
+		"service/local/repositories/"+artifact.RepositoryID+"/content"+path, nil,
+		nil, http.Header{"Accept": {"*/*"}})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	hash := sha1.New()
+	if _, err := io.Copy(io.MultiWriter(w, hash), resp.Body); err != nil {
+		return "", err
+	}
+
+	actual := hex.EncodeToString(hash.Sum(nil))
+	if info.Sha1 != "" && !strings.EqualFold(info.Sha1, actual) {
+		return "", &ChecksumError{Artifact: artifact, Expected: info.Sha1, Actual: actual}
+	}
+
+	return path, nil
+}
+
+// DownloadTo downloads the given artifact to the directory dir, like Download,
+// returning the path of the file created. The file has the same name as in
+// Nexus, and is only created if the download succeeds; if the checksums don't
+// match, nothing is left behind.
+func (nexus Nexus2x) DownloadTo(artifact *Artifact, dir string) (string, error) {
+	return nexus.DownloadToContext(context.Background(), artifact, dir)
+}
+
+// DownloadToContext behaves like DownloadTo, but bound to the given context.
+func (nexus Nexus2x) DownloadToContext(ctx context.Context, artifact *Artifact, dir string) (string, error) {
+	// the file name is only known after resolving, so download to a temporary
+	// file and rename it at the end
+	file, err := os.CreateTemp(dir, ".nexus-download-*")
+	if err != nil {
+		return "", err
+	}
+
+	path, err := nexus.download(ctx, artifact, file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+
+	target := filepath.Join(dir, filepath.Base(filepath.FromSlash(path)))
+	if err := os.Rename(file.Name(), target); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+
+	return target, nil
+}
@@ -0,0 +1,110 @@
+package nexus
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"sbrubbles.org/go/nexus/credentials"
+)
+
+// serves a single artifact with the given content, reporting the given SHA1.
+func downloadServer(t *testing.T, content string, sha1 string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, _, ok := r.BasicAuth(); !ok || user != "u" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch {
+		case r.URL.Path == "/service/local/artifact/maven/resolve":
+			w.Write([]byte("<artifact-resolution><data><repositoryPath>/g/a/1/a-1.jar</repositoryPath></data></artifact-resolution>"))
+		case r.URL.Path == "/service/local/repositories/releases/content/g/a/1/a-1.jar" && r.URL.Query().Get("describe") == "info":
+			w.Write([]byte("<org.sonatype.nexus.rest.model.ResourceResponse><data><sha1Hash>" + sha1 + "</sha1Hash></data></org.sonatype.nexus.rest.model.ResourceResponse>"))
+		case r.URL.Path == "/service/local/repositories/releases/content/g/a/1/a-1.jar":
+			w.Write([]byte(content))
+		default:
+			t.Errorf("Unexpected request: %v", r.URL)
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func sha1Of(content string) string {
+	sum := sha1.Sum([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+var downloadedArtifact = &Artifact{GroupID: "g", ArtifactID: "a", Version: "1", Extension: "jar", RepositoryID: "releases"}
+
+func TestDownloadChecksTheSha1(t *testing.T) {
+	server := downloadServer(t, "the content", sha1Of("the content"))
+	defer server.Close()
+
+	n := New(server.URL, credentials.BasicAuth("u", "p")).(*Nexus2x)
+
+	var buf bytes.Buffer
+	if err := n.Download(downloadedArtifact, &buf); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if buf.String() != "the content" {
+		t.Errorf("Expected 'the content', got %q", buf.String())
+	}
+}
+
+func TestDownloadFailsOnAChecksumMismatch(t *testing.T) {
+	server := downloadServer(t, "the content", sha1Of("something else"))
+	defer server.Close()
+
+	n := New(server.URL, credentials.BasicAuth("u", "p")).(*Nexus2x)
+
+	err := n.Download(downloadedArtifact, &bytes.Buffer{})
+	if e, ok := err.(*ChecksumError); !ok {
+		t.Errorf("Expected a *ChecksumError, got %v", err)
+	} else if e.Actual != sha1Of("the content") {
+		t.Errorf("Unexpected actual SHA1 in %v", e)
+	}
+}
+
+func TestDownloadToCreatesTheFile(t *testing.T) {
+	server := downloadServer(t, "the content", sha1Of("the content"))
+	defer server.Close()
+
+	dir := t.TempDir()
+	n := New(server.URL, credentials.BasicAuth("u", "p")).(*Nexus2x)
+
+	path, err := n.DownloadTo(downloadedArtifact, dir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if path != filepath.Join(dir, "a-1.jar") {
+		t.Errorf("Unexpected path %v", path)
+	}
+
+	if content, err := os.ReadFile(path); err != nil || string(content) != "the content" {
+		t.Errorf("Unexpected content %q (%v)", content, err)
+	}
+}
+
+func TestDownloadToLeavesNothingBehindOnAMismatch(t *testing.T) {
+	server := downloadServer(t, "the content", sha1Of("something else"))
+	defer server.Close()
+
+	dir := t.TempDir()
+	n := New(server.URL, credentials.BasicAuth("u", "p")).(*Nexus2x)
+
+	if _, err := n.DownloadTo(downloadedArtifact, dir); err == nil {
+		t.Fatalf("Expected an error!")
+	}
+
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("Expected an empty directory, got %v", entries)
+	}
+}
@@ -22,13 +22,14 @@ func (err Error) Error() string {
 }
 
 // Nexus' API returns error messages sometimes; this function is an attempt to
-// capture and return them to the caller.
-func (nexus Nexus2x) errorFromResponse(response *http.Response) Error {
+// capture and return them to the caller. url is the Nexus instance which
+// responded.
+func errorFromResponse(url string, response *http.Response) Error {
 	e := Error{
-		URL:        nexus.URL,
+		URL:        url,
 		StatusCode: response.StatusCode,
 		Status:     response.Status,
-		Message:    fmt.Sprintf("Error (%v) from %v", response.Status, nexus.URL),
+		Message:    fmt.Sprintf("Error (%v) from %v", response.Status, url),
 	}
 
 	body, err := bodyToBytes(response.Body)
@@ -94,3 +95,78 @@ func tryFromHTML(body []byte) (string, error) {
 	// the second element
 	return matches[1], nil
 }
+
+// TooManyResultsError is returned when Nexus refuses a search because it
+// matches too many artifacts, and the search can't be split into narrower
+// ones (e.g. a search by keyword in a single repository).
+type TooManyResultsError struct {
+	URL        string            // the Nexus instance which refused the search
+	Parameters map[string]string // the search's, e.g. map[g:org*]
+}
+
+// Error implements the error interface.
+func (err TooManyResultsError) Error() string {
+	return fmt.Sprintf("Too many results in %v for %v", err.URL, err.Parameters)
+}
+
+// BranchError is the failure of a single branch of a search split by
+// repository and group ID prefix (e.g. search.All or search.ByRepository), or
+// of a search with too many results split into narrower ones.
+type BranchError struct {
+	RepositoryID string // e.g. releases
+	Prefix       string // e.g. org*, or org:app* if split by artifact ID; empty if the whole repository failed
+	Err          error  // what went wrong
+}
+
+// Error implements the error interface.
+func (err BranchError) Error() string {
+	if err.Prefix == "" {
+		return fmt.Sprintf("%v: %v", err.RepositoryID, err.Err)
+	}
+
+	return fmt.Sprintf("%v (%v): %v", err.RepositoryID, err.Prefix, err.Err)
+}
+
+// Unwrap returns the underlying error.
+func (err BranchError) Unwrap() error {
+	return err.Err
+}
+
+// MultiError is returned by a best effort search (see Nexus2x.BestEffort)
+// when some of its branches failed. The search's results are still returned,
+// but they'll be missing what the failed branches would've found.
+type MultiError struct {
+	Errors []*BranchError // the failed branches
+}
+
+// Error implements the error interface.
+func (err MultiError) Error() string {
+	msgs := make([]string, len(err.Errors))
+	for i, e := range err.Errors {
+		msgs[i] = e.Error()
+	}
+
+	return fmt.Sprintf("%v branch(es) failed: %v", len(err.Errors), strings.Join(msgs, "; "))
+}
+
+// Unwrap returns the failed branches' errors.
+func (err MultiError) Unwrap() []error {
+	errs := make([]error, len(err.Errors))
+	for i, e := range err.Errors {
+		errs[i] = e
+	}
+
+	return errs
+}
+
+// adds err to the list, flattening nested *MultiErrors.
+func (err *MultiError) add(e error) {
+	switch e := e.(type) {
+	case *MultiError:
+		err.Errors = append(err.Errors, e.Errors...)
+	case *BranchError:
+		err.Errors = append(err.Errors, e)
+	default:
+		err.Errors = append(err.Errors, &BranchError{Err: e})
+	}
+}
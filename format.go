@@ -0,0 +1,175 @@
+package nexus
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// WireFormat is the format Nexus 2.x sends its responses in. Both carry the
+// same information, so the results are the same either way; JSON is faster to
+// decode, which shows on large searches.
+type WireFormat int
+
+const (
+	// XML is Nexus 2.x's default format.
+	XML WireFormat = iota
+
+	// JSON is the same as XML, only in JSON.
+	JSON
+)
+
+// String implements the fmt.Stringer interface.
+func (format WireFormat) String() string {
+	if format == JSON {
+		return "JSON"
+	}
+
+	return "XML"
+}
+
+// the media type to ask Nexus for.
+func (format WireFormat) mediaType() string {
+	if format == JSON {
+		return "application/json"
+	}
+
+	return "application/xml"
+}
+
+// decodes body, in this format, into payload, as it's read.
+func (format WireFormat) decode(body io.Reader, payload interface{}) error {
+	if format == JSON {
+		return json.NewDecoder(body).Decode(payload)
+	}
+
+	return xml.NewDecoder(body).Decode(payload)
+}
+
+// decodes one item of a list; see eachInData.
+type decodeFunc func(item interface{}) error
+
+// streams the items of the list in body's data (e.g. the artifacts in a lucene
+// page), calling fn for each one as soon as it's read, instead of decoding the
+// whole list first. In XML, the items are the data's child elements with the
+// given name; in JSON, the elements of the data array. fn must decode its item
+// with the given function, exactly once.
+//
+// If fields isn't nil, it gets the text of the other fields in the root (e.g.
+// the lucene search's totalCount). Everything else in body is skipped.
+func (format WireFormat) eachInData(body io.Reader, element string, fields map[string]string, fn func(decode decodeFunc) error) error {
+	if fields == nil {
+		fields = map[string]string{} // simpler than checking every time
+	}
+
+	if format == JSON {
+		return eachInJSONData(json.NewDecoder(body), fields, fn)
+	}
+
+	return eachInXMLData(xml.NewDecoder(body), element, fields, fn)
+}
+
+func eachInXMLData(d *xml.Decoder, element string, fields map[string]string, fn func(decode decodeFunc) error) error {
+	depth := 0 // 1 in the root element, 2 in data
+	for {
+		token, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch {
+			case depth == 0 || (depth == 1 && t.Name.Local == "data"):
+				depth++
+			case depth == 1:
+				var text string
+				err = d.DecodeElement(&text, &t)
+				fields[t.Name.Local] = text
+			case depth == 2 && t.Name.Local == element:
+				err = fn(func(item interface{}) error { return d.DecodeElement(item, &t) })
+			default:
+				err = d.Skip()
+			}
+		case xml.EndElement:
+			depth--
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func eachInJSONData(d *json.Decoder, fields map[string]string, fn func(decode decodeFunc) error) error {
+	if err := expectDelim(d, '{'); err != nil {
+		return err
+	}
+
+	for d.More() {
+		key, err := d.Token()
+		if err != nil {
+			return err
+		}
+
+		if key != "data" {
+			var field json.RawMessage
+			if err := d.Decode(&field); err != nil {
+				return err
+			}
+
+			// strings without the quotes; the rest as is
+			var text string
+			if json.Unmarshal(field, &text) != nil {
+				text = string(field)
+			}
+
+			fields[key.(string)] = text
+			continue
+		}
+
+		token, err := d.Token()
+		if err != nil {
+			return err
+		}
+
+		if token == nil { // null; no data
+			continue
+		}
+
+		if token != json.Delim('[') {
+			return fmt.Errorf("Expected [ in JSON, got %v", token)
+		}
+
+		for d.More() {
+			if err := fn(d.Decode); err != nil {
+				return err
+			}
+		}
+
+		if err := expectDelim(d, ']'); err != nil {
+			return err
+		}
+	}
+
+	return expectDelim(d, '}')
+}
+
+// reads the next token, which should be the given delimiter.
+func expectDelim(d *json.Decoder, delim json.Delim) error {
+	token, err := d.Token()
+	if err != nil {
+		return err
+	}
+
+	if token != delim {
+		return fmt.Errorf("Expected %v in JSON, got %v", delim, token)
+	}
+
+	return nil
+}
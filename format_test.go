@@ -0,0 +1,171 @@
+package nexus_test
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"sbrubbles.org/go/nexus"
+	"sbrubbles.org/go/nexus/credentials"
+	"sbrubbles.org/go/nexus/nexustest"
+	"sbrubbles.org/go/nexus/search"
+)
+
+// counts the successful responses whose Content-Type is not the expected one;
+// errors come as HTML when XML is asked for.
+type contentTypeChecker struct {
+	expected   string
+	mismatches int32
+}
+
+func (c *contentTypeChecker) RoundTrip(request *http.Request) (*http.Response, error) {
+	response, err := http.DefaultTransport.RoundTrip(request)
+	if err == nil && response.StatusCode == http.StatusOK && !strings.HasPrefix(response.Header.Get("Content-Type"), c.expected) {
+		atomic.AddInt32(&c.mismatches, 1)
+	}
+
+	return response, err
+}
+
+// a server with a bit of everything, and small quirky pages, so the searches
+// take a few requests.
+func formatServer() *nexustest.Server {
+	server := nexustest.NewUnstartedServer(&nexustest.Fixture{
+		Repositories: []*nexus.Repository{
+			{ID: "releases", Name: "Releases", Type: "hosted", Format: "maven2", Policy: "RELEASE"},
+			{ID: "snapshots", Name: "Snapshots", Type: "hosted", Format: "maven2", Policy: "SNAPSHOT"},
+			{ID: "central", Name: "Central", Type: "proxy", Format: "maven2", Policy: "RELEASE", RemoteURI: "https://repo1.maven.org/maven2/"},
+		},
+		Groups: []*nexus.Group{
+			{ID: "public", Name: "Public", Format: "maven2", Policy: "MIXED", MemberIDs: []string{"releases", "central"}},
+		},
+		Artifacts: []*nexustest.Artifact{
+			nexustest.NewArtifact("com.acme:app:jar:1.0@releases", "app"),
+			nexustest.NewArtifact("com.acme:app:jar:sources:1.0@releases", "app sources"),
+			nexustest.NewArtifact("com.acme:app:pom:1.0@releases", "<project/>"),
+			nexustest.NewArtifact("com.acme:app:jar:2.0-SNAPSHOT@snapshots", "app 2"),
+			nexustest.NewArtifact("com.acme:lib:jar:1.1@releases", "lib"),
+			nexustest.NewArtifact("com.acme:lib:pom:1.1@releases", "<project/>"),
+			nexustest.NewArtifact("org.example:tool:war:3@central", "tool"),
+			nexustest.NewArtifact("org.example:tool:jar:javadoc:3@central", "tool docs"),
+		},
+	})
+	server.PageSize = 2
+	server.Quirky = true
+	server.Start()
+
+	return server
+}
+
+func clientFor(server *nexustest.Server, format nexus.WireFormat, checker *contentTypeChecker) nexus.Nexus2x {
+	return nexus.Nexus2x{
+		URL:         server.URL,
+		Credentials: credentials.None,
+		HTTPClient:  &http.Client{Transport: checker},
+		Format:      format,
+	}
+}
+
+func sortedCoordinates(artifacts []*nexus.Artifact) []string {
+	result := []string{}
+	for _, artifact := range artifacts {
+		result = append(result, artifact.String())
+	}
+
+	sort.Strings(result)
+	return result
+}
+
+func TestXMLAndJSONAgree(t *testing.T) {
+	server := formatServer()
+	defer server.Close()
+
+	xmlChecker := &contentTypeChecker{expected: "application/xml"}
+	jsonChecker := &contentTypeChecker{expected: "application/json"}
+	xmlClient := clientFor(server, nexus.XML, xmlChecker)
+	jsonClient := clientFor(server, nexus.JSON, jsonChecker)
+
+	for _, criteria := range []search.Criteria{
+		search.ByCoordinates{GroupID: "com.acme"},
+		search.ByCoordinates{ArtifactID: "app", Classifier: "sources"},
+		search.ByKeyword("tool"),
+		search.InRepository{RepositoryID: "releases", Criteria: search.ByCoordinates{Packaging: "pom"}},
+		search.All,
+	} {
+		fromXML, err := xmlClient.Artifacts(criteria)
+		if err != nil {
+			t.Fatalf("%v: unexpected error %v", criteria, err)
+		}
+
+		fromJSON, err := jsonClient.Artifacts(criteria)
+		if err != nil {
+			t.Fatalf("%v: unexpected error %v", criteria, err)
+		}
+
+		if len(fromXML) == 0 || !reflect.DeepEqual(sortedCoordinates(fromXML), sortedCoordinates(fromJSON)) {
+			t.Errorf("%v: expected %v, got %v", criteria, sortedCoordinates(fromXML), sortedCoordinates(fromJSON))
+		}
+	}
+
+	xmlRepos, err := xmlClient.Repositories()
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	jsonRepos, err := jsonClient.Repositories()
+	if err != nil || !reflect.DeepEqual(xmlRepos, jsonRepos) {
+		t.Errorf("Expected %v, got %v (error %v)", xmlRepos, jsonRepos, err)
+	}
+
+	artifact := &nexus.Artifact{GroupID: "com.acme", ArtifactID: "app", Version: "1.0", Extension: "jar", RepositoryID: "releases"}
+	xmlInfo, err := xmlClient.InfoOf(artifact)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	jsonInfo, err := jsonClient.InfoOf(artifact)
+	if err != nil || !reflect.DeepEqual(xmlInfo, jsonInfo) {
+		t.Errorf("Expected %v, got %v (error %v)", xmlInfo, jsonInfo, err)
+	}
+
+	xmlRepoInfo, err := xmlClient.RepositoryInfo("central")
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	jsonRepoInfo, err := jsonClient.RepositoryInfo("central")
+	if err != nil || !reflect.DeepEqual(xmlRepoInfo, jsonRepoInfo) {
+		t.Errorf("Expected %v, got %v (error %v)", xmlRepoInfo, jsonRepoInfo, err)
+	}
+
+	xmlGroups, err := xmlClient.Groups()
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	jsonGroups, err := jsonClient.Groups()
+	if err != nil || !reflect.DeepEqual(xmlGroups, jsonGroups) {
+		t.Errorf("Expected %v, got %v (error %v)", xmlGroups, jsonGroups, err)
+	}
+
+	members, err := jsonClient.MembersOf(jsonGroups[0])
+	if err != nil || len(members) != 2 || members[0].ID != "releases" || members[1].ID != "central" {
+		t.Errorf("Expected releases and central, got %v (error %v)", members, err)
+	}
+
+	// the errors too
+	missing := &nexus.Artifact{GroupID: "com.acme", ArtifactID: "nope", Version: "1.0", Extension: "jar", RepositoryID: "releases"}
+	_, xmlErr := xmlClient.InfoOf(missing)
+	_, jsonErr := jsonClient.InfoOf(missing)
+	if xmlErr == nil || jsonErr == nil || xmlErr.Error() != jsonErr.Error() {
+		t.Errorf("Expected the same error, got %v and %v", xmlErr, jsonErr)
+	}
+
+	if xmlChecker.mismatches != 0 || jsonChecker.mismatches != 0 {
+		t.Errorf("Expected every response in the asked format, got %v XML and %v JSON mismatches",
+			xmlChecker.mismatches, jsonChecker.mismatches)
+	}
+}
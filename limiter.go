@@ -0,0 +1,62 @@
+package nexus
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// Limiter caps the number of requests in flight to Nexus. A single Limiter may
+// be shared by several clients, so that all of them together don't go over
+// the cap. The zero value isn't usable; use NewLimiter instead. A nil *Limiter
+// imposes no cap at all.
+//
+// A request holds its slot until its response's body is closed. The searches
+// read their pages apart from the code the artifacts are handed to (e.g. a
+// WalkFunc), which can then make requests through the same Limiter without
+// deadlocking.
+type Limiter struct {
+	slots chan struct{}
+}
+
+// NewLimiter returns a Limiter allowing at most max requests in flight. A
+// max < 1 is taken as 1.
+func NewLimiter(max int) *Limiter {
+	if max < 1 {
+		max = 1
+	}
+
+	return &Limiter{slots: make(chan struct{}, max)}
+}
+
+// waits until there's a free slot or ctx is done, returning a function which
+// frees the slot taken. Safe to call on a nil *Limiter.
+func (l *Limiter) acquire(ctx context.Context) (release func(), err error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case l.slots <- empty:
+		return func() { <-l.slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// frees the Limiter slot taken by a request when its response body is closed.
+// Close may be called more than once, but the slot is only freed once.
+type releasingBody struct {
+	io.ReadCloser
+
+	release func()
+	once    sync.Once
+}
+
+// Close implements the io.Closer interface.
+func (body *releasingBody) Close() error {
+	err := body.ReadCloser.Close()
+	body.once.Do(body.release)
+
+	return err
+}
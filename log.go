@@ -0,0 +1,166 @@
+package nexus
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxLoggedBody is how much of a response's body is logged at the Debug level.
+const MaxLoggedBody = 2048
+
+// Redacted replaces the secrets (e.g. credentials, cookies, tokens) in the
+// logs, and in nexustest's cassettes.
+const Redacted = "REDACTED"
+
+// the headers which hold secrets.
+var secretHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// the query parameters holding secrets have one of these in their names.
+var secretParams = []string{"token", "password", "secret", "apikey", "api_key"}
+
+// the given URL, without secrets in the user info or in the query.
+func redactURL(u *url.URL) string {
+	redacted := *u
+	if redacted.User != nil {
+		redacted.User = url.UserPassword(redacted.User.Username(), Redacted)
+	}
+
+	query := redacted.Query()
+	for key := range query {
+		lower := strings.ToLower(key)
+		for _, secret := range secretParams {
+			if strings.Contains(lower, secret) {
+				query[key] = []string{Redacted}
+				break
+			}
+		}
+	}
+
+	redacted.RawQuery = query.Encode()
+	return redacted.String()
+}
+
+// RedactHeader returns a copy of header, with the values of the headers
+// holding secrets (e.g. Authorization, Cookie) replaced by Redacted.
+func RedactHeader(header http.Header) http.Header {
+	redacted := header.Clone()
+	for _, key := range secretHeaders {
+		if _, ok := redacted[key]; ok {
+			redacted[key] = []string{Redacted}
+		}
+	}
+
+	return redacted
+}
+
+// logs the given request, which started at start and ended with response and
+// err (after the retries). Successful requests are logged at the Info level,
+// and failed ones at the Warn level, with the error's message.
+func (nexus Nexus2x) logRequest(ctx context.Context, request *http.Request, start time.Time, attempts int,
+	response *http.Response, err error) {
+	if nexus.Logger == nil {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("method", request.Method),
+		slog.String("url", redactURL(request.URL)),
+		slog.Duration("duration", time.Since(start)),
+		slog.Int("attempts", attempts),
+	}
+
+	if response != nil {
+		attrs = append(attrs, slog.Int("status", response.StatusCode))
+	}
+
+	level := slog.LevelInfo
+	if err != nil {
+		level = slog.LevelWarn
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+
+	nexus.Logger.LogAttrs(ctx, level, "nexus request", attrs...)
+}
+
+// at the Debug level, logs the headers and the beginning of the body of the
+// given response when the body is closed, so the response is returned with its
+// body wrapped.
+func (nexus Nexus2x) logResponse(ctx context.Context, request *http.Request, response *http.Response) *http.Response {
+	if nexus.Logger == nil || response == nil || !nexus.Logger.Enabled(ctx, slog.LevelDebug) {
+		return response
+	}
+
+	response.Body = &loggedBody{
+		ReadCloser: response.Body,
+		dump: func(body []byte, truncated bool) {
+			attrs := []slog.Attr{
+				slog.String("method", request.Method),
+				slog.String("url", redactURL(request.URL)),
+				slog.Int("status", response.StatusCode),
+				slog.Any("request_header", RedactHeader(request.Header)),
+				slog.Any("response_header", RedactHeader(response.Header)),
+			}
+
+			if isText(response.Header.Get("Content-Type")) {
+				attrs = append(attrs, slog.String("body", string(body)), slog.Bool("truncated", truncated))
+			}
+
+			nexus.Logger.LogAttrs(ctx, slog.LevelDebug, "nexus response", attrs...)
+		},
+	}
+
+	return response
+}
+
+// if the content type is something worth logging.
+func isText(contentType string) bool {
+	for _, text := range []string{"text/", "xml", "json"} {
+		if strings.Contains(contentType, text) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// a response body which keeps the first MaxLoggedBody bytes read, and dumps
+// them when closed.
+type loggedBody struct {
+	io.ReadCloser
+
+	dump      func(body []byte, truncated bool)
+	kept      bytes.Buffer
+	truncated bool
+	once      sync.Once
+}
+
+// Read implements the io.Reader interface.
+func (body *loggedBody) Read(p []byte) (int, error) {
+	n, err := body.ReadCloser.Read(p)
+
+	room := MaxLoggedBody - body.kept.Len()
+	switch {
+	case n <= room:
+		body.kept.Write(p[:n])
+	default:
+		body.kept.Write(p[:room])
+		body.truncated = true
+	}
+
+	return n, err
+}
+
+// Close implements the io.Closer interface.
+func (body *loggedBody) Close() error {
+	err := body.ReadCloser.Close()
+	body.once.Do(func() { body.dump(body.kept.Bytes(), body.truncated) })
+
+	return err
+}
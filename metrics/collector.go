@@ -0,0 +1,181 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultLatencyBounds are the upper bounds of the latency histograms' buckets.
+var DefaultLatencyBounds = []time.Duration{
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+}
+
+// DefaultSizeBounds are the upper bounds, in bytes, of the response size
+// histograms' buckets.
+var DefaultSizeBounds = []int64{1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20, 4 << 20, 16 << 20}
+
+// Histogram counts values in buckets. Counts[i] holds the values up to
+// Bounds[i] (and above the previous bound), and the last count, the values
+// above every bound.
+type Histogram struct {
+	Bounds []int64 // the buckets' upper bounds, inclusive, in ascending order
+	Counts []int64 // one per bound, plus one for the values above them all
+	Sum    int64   // the sum of all values
+	Count  int64   // how many values there are
+}
+
+func newHistogram(bounds []int64) Histogram {
+	return Histogram{
+		Bounds: append([]int64{}, bounds...),
+		Counts: make([]int64, len(bounds)+1),
+	}
+}
+
+func (h *Histogram) add(value int64) {
+	i := sort.Search(len(h.Bounds), func(i int) bool { return value <= h.Bounds[i] })
+	h.Counts[i]++
+	h.Sum += value
+	h.Count++
+}
+
+func (h Histogram) clone() Histogram {
+	h.Bounds = append([]int64{}, h.Bounds...)
+	h.Counts = append([]int64{}, h.Counts...)
+	return h
+}
+
+// Stats holds the counters and histograms of an endpoint.
+type Stats struct {
+	Requests int64         // how many requests there were
+	Failures int64         // how many of them failed (see Request.Failed)
+	Retries  int64         // how many retries there were, all in all
+	Bytes    int64         // how many bytes were read, all in all
+	Statuses map[int]int64 // how many requests got each status; 0 means no response
+	Latency  Histogram     // of the requests' latencies, in nanoseconds
+	Size     Histogram     // of the bytes read from each response
+}
+
+// Snapshot is the state of a Collector at some point, by endpoint.
+type Snapshot map[Endpoint]Stats
+
+// Total adds up the stats of every endpoint.
+func (s Snapshot) Total() Stats {
+	total := Stats{Statuses: map[int]int64{}}
+	for _, stats := range s {
+		total.Requests += stats.Requests
+		total.Failures += stats.Failures
+		total.Retries += stats.Retries
+		total.Bytes += stats.Bytes
+
+		for status, count := range stats.Statuses {
+			total.Statuses[status] += count
+		}
+	}
+
+	return total
+}
+
+// Collector is an Observer which keeps counters and histograms for each
+// endpoint. It's safe for concurrent use.
+type Collector struct {
+	LatencyBounds []time.Duration // DefaultLatencyBounds if empty
+	SizeBounds    []int64         // DefaultSizeBounds if empty
+
+	mutex sync.Mutex // guards stats
+	stats map[Endpoint]*Stats
+}
+
+// NewCollector creates a new Collector with the default buckets.
+func NewCollector() *Collector {
+	return &Collector{}
+}
+
+// Observe implements the Observer interface.
+func (c *Collector) Observe(request Request) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.stats == nil {
+		c.stats = map[Endpoint]*Stats{}
+	}
+
+	stats, ok := c.stats[request.Endpoint]
+	if !ok {
+		stats = c.newStats()
+		c.stats[request.Endpoint] = stats
+	}
+
+	stats.Requests++
+	if request.Failed() {
+		stats.Failures++
+	}
+
+	stats.Retries += int64(request.Retries)
+	stats.Bytes += request.Bytes
+	stats.Statuses[request.StatusCode]++
+	stats.Latency.add(int64(request.Latency))
+	stats.Size.add(request.Bytes)
+}
+
+func (c *Collector) newStats() *Stats {
+	latencyBounds := c.LatencyBounds
+	if len(latencyBounds) == 0 {
+		latencyBounds = DefaultLatencyBounds
+	}
+
+	bounds := make([]int64, len(latencyBounds))
+	for i, bound := range latencyBounds {
+		bounds[i] = int64(bound)
+	}
+
+	sizeBounds := c.SizeBounds
+	if len(sizeBounds) == 0 {
+		sizeBounds = DefaultSizeBounds
+	}
+
+	return &Stats{
+		Statuses: map[int]int64{},
+		Latency:  newHistogram(bounds),
+		Size:     newHistogram(sizeBounds),
+	}
+}
+
+// Snapshot returns a copy of the stats collected so far, which isn't affected
+// by later requests.
+func (c *Collector) Snapshot() Snapshot {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	snapshot := Snapshot{}
+	for endpoint, stats := range c.stats {
+		copied := *stats
+		copied.Statuses = map[int]int64{}
+		for status, count := range stats.Statuses {
+			copied.Statuses[status] = count
+		}
+
+		copied.Latency = stats.Latency.clone()
+		copied.Size = stats.Size.clone()
+		snapshot[endpoint] = copied
+	}
+
+	return snapshot
+}
+
+// Reset forgets everything collected so far.
+func (c *Collector) Reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.stats = nil
+}
@@ -0,0 +1,76 @@
+/*
+Package metrics tells what a Nexus client is doing to Nexus. A client calls its
+Observer once for every request it makes, with the kind of endpoint it went to,
+how it went and how long it took.
+
+Collector is a ready-made Observer, which keeps counters and histograms per
+endpoint, and can be read at any time with Snapshot.
+*/
+package metrics // import "sbrubbles.org/go/nexus/metrics"
+
+import (
+	"time"
+)
+
+// Endpoint is the kind of Nexus endpoint a request went to.
+type Endpoint string
+
+const (
+	Search         Endpoint = "search"          // the lucene search
+	ContentListing Endpoint = "content listing" // a directory in a repository
+	Resolve        Endpoint = "resolve"         // an artifact's path in its repository
+	Info           Endpoint = "info"            // the information about an artifact
+	Repositories   Endpoint = "repositories"    // the repositories, groups and their settings
+	Content        Endpoint = "content"         // downloads, deployments and deletions
+	Other          Endpoint = "other"           // anything else, e.g. the status
+)
+
+// Request is what an Observer learns about each request made to Nexus.
+type Request struct {
+	Endpoint   Endpoint      // e.g. metrics.Search
+	Method     string        // e.g. GET
+	StatusCode int           // e.g. 200; 0 if there was no response
+	Latency    time.Duration // until the response's headers arrived, counting the retries
+	Bytes      int64         // how much of the response's body was read
+	Retries    int           // how many times the request was retried
+	Err        error         // if the request failed without a response, why
+}
+
+// Failed tells if the request failed, either without a response or with an
+// error status.
+func (request Request) Failed() bool {
+	return request.StatusCode == 0 || request.StatusCode >= 400
+}
+
+// Observer is told about every request a Nexus client makes. Requests with a
+// response are observed when its body is closed, so the bytes read can be
+// counted. Observers are called concurrently, so they must be safe for
+// concurrent use.
+type Observer interface {
+	Observe(request Request)
+}
+
+// None is the zero value for Observer, which ignores everything.
+const None = noObserver(true)
+
+type noObserver bool // it's bool for Go to allow a const
+
+func (o noObserver) Observe(request Request) {}
+
+// OrZero returns the given observer untouched if it's not nil, and
+// metrics.None otherwise.
+func OrZero(o Observer) Observer {
+	if o == nil {
+		return None
+	}
+
+	return o
+}
+
+// ObserverFunc is an adapter to use ordinary functions as Observers.
+type ObserverFunc func(request Request)
+
+// Observe implements the Observer interface, calling f.
+func (f ObserverFunc) Observe(request Request) {
+	f(request)
+}
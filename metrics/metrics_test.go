@@ -0,0 +1,104 @@
+package metrics_test
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"sbrubbles.org/go/nexus/metrics"
+)
+
+func TestNoneImplementsObserver(t *testing.T) {
+	if _, ok := interface{}(metrics.None).(metrics.Observer); !ok {
+		t.Errorf("metrics.None doesn't implement metrics.Observer!")
+	}
+}
+
+func TestOrZeroReturnsNoneOnNil(t *testing.T) {
+	if v := metrics.OrZero(nil); v != metrics.None {
+		t.Errorf("metrics.OrZero(nil) should've returned metrics.None, not %v!", v)
+	}
+
+	c := metrics.NewCollector()
+	if v := metrics.OrZero(c); v != c {
+		t.Errorf("metrics.OrZero(%v) should've returned %v, not %v!", c, c, v)
+	}
+}
+
+func TestCollectorCountsByEndpoint(t *testing.T) {
+	c := &metrics.Collector{
+		LatencyBounds: []time.Duration{10 * time.Millisecond, time.Second},
+		SizeBounds:    []int64{100, 1000},
+	}
+
+	c.Observe(metrics.Request{Endpoint: metrics.Search, StatusCode: 200, Latency: 5 * time.Millisecond, Bytes: 100})
+	c.Observe(metrics.Request{Endpoint: metrics.Search, StatusCode: 503, Latency: 2 * time.Second, Bytes: 10, Retries: 2})
+	c.Observe(metrics.Request{Endpoint: metrics.Info, Latency: time.Second, Err: errors.New("boom")})
+
+	snapshot := c.Snapshot()
+
+	search := snapshot[metrics.Search]
+	if search.Requests != 2 || search.Failures != 1 || search.Retries != 2 || search.Bytes != 110 ||
+		!reflect.DeepEqual(search.Statuses, map[int]int64{200: 1, 503: 1}) {
+		t.Errorf("Unexpected search stats %+v", search)
+	}
+
+	if !reflect.DeepEqual(search.Latency.Counts, []int64{1, 0, 1}) || search.Latency.Count != 2 ||
+		search.Latency.Sum != int64(2005*time.Millisecond) {
+		t.Errorf("Unexpected latency histogram %+v", search.Latency)
+	}
+
+	if !reflect.DeepEqual(search.Size.Counts, []int64{2, 0, 0}) {
+		t.Errorf("Unexpected size histogram %+v", search.Size)
+	}
+
+	info := snapshot[metrics.Info]
+	if info.Requests != 1 || info.Failures != 1 || info.Statuses[0] != 1 ||
+		!reflect.DeepEqual(info.Latency.Counts, []int64{0, 1, 0}) {
+		t.Errorf("Unexpected info stats %+v", info)
+	}
+
+	if total := snapshot.Total(); total.Requests != 3 || total.Failures != 2 || total.Bytes != 110 {
+		t.Errorf("Unexpected total %+v", total)
+	}
+}
+
+func TestSnapshotsArentAffectedByLaterRequests(t *testing.T) {
+	c := metrics.NewCollector()
+	c.Observe(metrics.Request{Endpoint: metrics.Search, StatusCode: 200})
+
+	snapshot := c.Snapshot()
+	c.Observe(metrics.Request{Endpoint: metrics.Search, StatusCode: 200})
+
+	if stats := snapshot[metrics.Search]; stats.Requests != 1 || stats.Statuses[200] != 1 || stats.Latency.Count != 1 {
+		t.Errorf("The snapshot changed: %+v", stats)
+	}
+
+	c.Reset()
+	if snapshot := c.Snapshot(); len(snapshot) != 0 {
+		t.Errorf("Expected nothing after a reset, got %v", snapshot)
+	}
+}
+
+func TestCollectorIsSafeForConcurrentUse(t *testing.T) {
+	c := metrics.NewCollector()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				c.Observe(metrics.Request{Endpoint: metrics.Search, StatusCode: 200, Bytes: 1})
+				c.Snapshot()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if stats := c.Snapshot()[metrics.Search]; stats.Requests != 800 || stats.Bytes != 800 {
+		t.Errorf("Expected 800 requests and bytes, got %+v", stats)
+	}
+}
@@ -1,39 +1,92 @@
 package nexus
 
 import (
-	"bytes"
-	"encoding/xml"
+	"context"
+	"errors"
 	"io"
+	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"sbrubbles.org/go/nexus/credentials"
+	"sbrubbles.org/go/nexus/metrics"
 	"sbrubbles.org/go/nexus/search"
 	"sbrubbles.org/go/nexus/util"
 )
 
 // Client accesses a Nexus instance. The default Client should work for the
-// newest Nexus versions. Older Nexus versions may need or benefit from a
-// specific client.
+// newest Nexus 2 versions. Older Nexus versions may need or benefit from a
+// specific client, and Nexus 3 needs one of its own (see Nexus3x).
+//
+// Every method has a ...Context variant, which stops (and returns the
+// context's error) as soon as the given context is done; the plain ones use
+// context.Background().
 type Client interface {
 	// Returns all artifacts in this Nexus which satisfy the given criteria.
 	// Nil is the same as search.All. If no criteria are given
-	// (e.g. search.All), it does a full search in all repositories.
+	// (e.g. search.All), it does a full search in all repositories. Composite
+	// criteria (e.g. search.Or) which Nexus can't run in a single search are
+	// planned into several, whose results are combined by the client.
 	Artifacts(criteria search.Criteria) ([]*Artifact, error)
+	ArtifactsContext(ctx context.Context, criteria search.Criteria) ([]*Artifact, error)
 
 	// Returns all repositories in this Nexus.
 	Repositories() ([]*Repository, error)
+	RepositoriesContext(ctx context.Context) ([]*Repository, error)
 
 	// Returns extra information about the given artifact.
 	InfoOf(artifact *Artifact) (*ArtifactInfo, error)
+	InfoOfContext(ctx context.Context, artifact *Artifact) (*ArtifactInfo, error)
 }
 
 // Nexus2x represents a Nexus v2.x instance. It's the default Client
 // implementation.
+//
+// Some searches (e.g. search.All, search.ByRepository) are split in several
+// lucene queries, run in parallel by at most MaxParallelism goroutines per
+// split; the pages of each query are fetched in parallel too, once the first
+// one tells how many there are. Since these searches nest (a full search
+// splits by repository, and then each repository by first-level directory,
+// and then each query by page), a Limiter may be given to cap the number of
+// requests in flight for the whole client, or even for several clients
+// sharing it.
+//
+// By default, a split search fails as soon as any of its branches does. With
+// BestEffort, the other branches go on (the composite searches' too, see
+// search.Or), and the search returns what they found along with a *MultiError,
+// listing which branches failed and why.
+//
+// Nexus is asked for XML, unless Format says otherwise. If there's an Observer,
+// it's told about every request made, e.g. to see which searches hammer Nexus
+// the most. If there's a Logger, every request is logged, without secrets; at
+// the Debug level, along with its headers and the beginning of its response.
 type Nexus2x struct {
-	URL         string                  // e.g. http://somewhere.com:8080/nexus
-	Credentials credentials.Credentials // e.g. credentials.BasicAuth("u", "p")
-	HTTPClient  *http.Client            // the network client
+	URL            string                  // e.g. http://somewhere.com:8080/nexus
+	Credentials    credentials.Credentials // e.g. credentials.BasicAuth("u", "p")
+	HTTPClient     *http.Client            // the network client
+	MaxParallelism int                     // e.g. 4; DefaultMaxParallelism if < 1
+	Limiter        *Limiter                // e.g. nexus.NewLimiter(16); nil means no cap
+	Retry          *RetryPolicy            // e.g. &nexus.DefaultRetryPolicy; nil means no retries
+	BestEffort     bool                    // if split searches go on when a branch fails
+	Format         WireFormat              // e.g. nexus.JSON; XML by default
+	Observer       metrics.Observer        // e.g. metrics.NewCollector(); nil means none
+	Logger         *slog.Logger            // e.g. slog.Default(); nil means no logging
+}
+
+// DefaultMaxParallelism is the number of parallel queries a Nexus2x runs per
+// split search, if its MaxParallelism isn't set.
+const DefaultMaxParallelism = 8
+
+// the number of parallel queries this client runs per split search.
+func (nexus Nexus2x) workers() int {
+	if nexus.MaxParallelism < 1 {
+		return DefaultMaxParallelism
+	}
+
+	return nexus.MaxParallelism
 }
 
 // New creates a new Nexus client, using the default Client implementation.
@@ -45,26 +98,75 @@ func New(url string, c credentials.Credentials) Client {
 }
 
 // does the actual legwork, going to Nexus and validating the response.
-func (nexus Nexus2x) fetch(path string, query map[string]string) (*http.Response, error) {
+// The request is bound to ctx, so cancelling it aborts the call.
+func (nexus Nexus2x) fetch(ctx context.Context, path string, query map[string]string) (*http.Response, error) {
+	// by default Nexus returns XML, but it's cheap to be explicit
+	return nexus.send(ctx, "GET", path, query, nil, http.Header{"Accept": {nexus.Format.mediaType()}})
+}
+
+// fetches the given path, decoding the response into payload as it arrives.
+// The response's body is closed either way.
+func (nexus Nexus2x) fetchInto(ctx context.Context, path string, query map[string]string, payload interface{}) error {
+	resp, err := nexus.fetch(ctx, path, query)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return nexus.Format.decode(resp.Body, payload)
+}
+
+// sends a request to Nexus with the given method, body and headers,
+// validating the response. fetch covers the common case.
+func (nexus Nexus2x) send(ctx context.Context, method string, path string, query map[string]string, body io.Reader, header http.Header) (*http.Response, error) {
 	fullURL, err := util.BuildFullURL(nexus.URL, path, query)
 	if err != nil {
 		return nil, err
 	}
 
-	get, err := http.NewRequest("GET", fullURL, nil)
+	request, err := http.NewRequestWithContext(ctx, method, fullURL, body)
 	if err != nil {
 		return nil, err
 	}
 
-	nexus.Credentials.Sign(get)
+	for key, values := range header {
+		request.Header[key] = values
+	}
 
-	// by default Nexus returns XML, but it's cheap to be explicit
-	get.Header.Add("Accept", "application/xml")
+	nexus.Credentials.Sign(request)
+
+	// go for it! Only GETs are safe to retry, though
+	var response *http.Response
+	attempts := 0
+	start := time.Now()
+	for {
+		attempts++
+
+		response, err = nexus.roundTrip(ctx, request)
+
+		if method != "GET" || !nexus.Retry.shouldRetry(attempts, response, err) {
+			break
+		}
+
+		delay := nexus.Retry.delay(attempts, response)
+		if response != nil { // this one won't be used, so let it go
+			response.Body.Close()
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			nexus.observe(request, path, query, start, attempts, nil, ctx.Err())
+			nexus.logRequest(ctx, request, start, attempts, nil, ctx.Err())
+			return nil, &RetryError{Attempts: attempts, Err: ctx.Err()}
+		}
+	}
 
-	// go for it!
-	response, err := nexus.HTTPClient.Do(get)
+	response = nexus.observe(request, path, query, start, attempts, response, err)
+	response = nexus.logResponse(ctx, request, response)
 	if err != nil {
-		return nil, err
+		nexus.logRequest(ctx, request, start, attempts, nil, err)
+		return nil, withAttempts(attempts, err)
 	}
 
 	// lets see if everything is alright
@@ -72,24 +174,48 @@ func (nexus Nexus2x) fetch(path string, query map[string]string) (*http.Response
 	switch {
 	case status == http.StatusUnauthorized:
 		// the credentials don't check out
-		return nil, &credentials.Error{URL: fullURL, Credentials: nexus.Credentials}
+		response.Body.Close()
+		err = &credentials.Error{URL: fullURL, Credentials: nexus.Credentials}
 	case 400 <= status && status < 600:
 		// Nexus complained, so error out
-		return nil, nexus.errorFromResponse(response)
+		err = errorFromResponse(nexus.URL, response)
+	}
+
+	nexus.logRequest(ctx, request, start, attempts, response, err)
+	if err != nil {
+		return nil, withAttempts(attempts, err)
 	}
 
 	// all is good, carry on
 	return response, nil
 }
 
-func bodyToBytes(body io.ReadCloser) ([]byte, error) {
-	buf := new(bytes.Buffer)
-	if _, err := buf.ReadFrom(body); err != nil {
+// a single attempt at the given request.
+func (nexus Nexus2x) roundTrip(ctx context.Context, request *http.Request) (*http.Response, error) {
+	// wait for a free slot, if there's a cap on the requests in flight. The
+	// slot is held until the response body is closed
+	release, err := nexus.Limiter.acquire(ctx)
+	if err != nil {
 		return nil, err
 	}
+
+	response, err := nexus.HTTPClient.Do(request.Clone(ctx))
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	response.Body = &releasingBody{ReadCloser: response.Body, release: release}
+	return response, nil
+}
+
+// reads the whole body, and closes it. Only for small bodies (e.g. errors);
+// the payloads are decoded as they arrive, with fetchInto or
+// WireFormat.eachInData.
+func bodyToBytes(body io.ReadCloser) ([]byte, error) {
 	defer body.Close() // don't forget to Close() body at the end!
 
-	return buf.Bytes(), nil
+	return io.ReadAll(body)
 }
 
 // Artifacts implements the Client interface, returning all artifacts in this
@@ -100,72 +226,135 @@ func bodyToBytes(body io.ReadCloser) ([]byte, error) {
 // Generally you don't want that, especially if you have proxy repositories;
 // Maven Central (which many people will proxy) has, at the time of this
 // comment, over 800,000 artifacts (!), which in this implementation will be
-// *all* loaded into memory (!!). But, if you insist...
+// *all* loaded into memory (!!). But, if you insist... or use WalkArtifacts,
+// which hands the artifacts over as they arrive, without piling them up.
 func (nexus Nexus2x) Artifacts(criteria search.Criteria) ([]*Artifact, error) {
-	params := search.OrZero(criteria).Parameters()
+	return nexus.ArtifactsContext(context.Background(), criteria)
+}
 
-	if len(params) == 0 { // full search
-		return nexus.fetchAllArtifacts()
+// ArtifactsContext implements the Client interface, behaving like Artifacts,
+// but bound to the given context. Cancelling ctx stops every in-flight
+// request, including the ones made in parallel by a full or a repository-wide
+// search.
+func (nexus Nexus2x) ArtifactsContext(ctx context.Context, criteria search.Criteria) ([]*Artifact, error) {
+	artifacts := []*Artifact{}
+
+	err := nexus.WalkArtifactsContext(ctx, criteria, func(artifact *Artifact) error {
+		artifacts = append(artifacts, artifact)
+		return nil
+	})
+
+	if _, partial := err.(*MultiError); partial { // best effort; return what's there
+		return artifacts, err
 	}
 
-	if len(params) == 1 {
-		if repoID, ok := params["repositoryId"]; ok { // all in repo search
-			return nexus.fetchArtifactsFrom(repoID)
-		}
+	if err != nil {
+		return nil, err
 	}
 
-	return nexus.fetchArtifactsWhere(params)
+	return artifacts, nil
 }
 
-// holds the relevant information from Nexus' artifact search.
-type artifactSearchResponse struct {
-	Count     int
-	Artifacts []*Artifact
+// WalkFunc is the type of the function called by WalkArtifacts for each
+// artifact found. If it returns an error, the walk stops and returns that
+// error, unless it's StopWalk, in which case the walk just stops.
+type WalkFunc func(artifact *Artifact) error
+
+// StopWalk is used as a return value from a WalkFunc to end a walk early. It
+// isn't returned as an error by any function.
+var StopWalk = errors.New("stop the walk")
+
+// WalkArtifacts searches this Nexus with the given criteria, like Artifacts
+// does, but instead of piling the results up, it calls fn for each artifact as
+// soon as it's read, while the lucene page holding it may still be downloading.
+// Every artifact is handed over only once, and fn is never called concurrently.
+// The pages are downloaded apart from fn, so it may use this client (e.g. for
+// InfoOfContext) even with a Limiter allowing a single request.
+//
+// Only a fingerprint of each artifact is kept to filter out repetitions, so a
+// full search is feasible even with a proxy of Maven Central.
+func (nexus Nexus2x) WalkArtifacts(criteria search.Criteria, fn WalkFunc) error {
+	return nexus.WalkArtifactsContext(context.Background(), criteria, fn)
 }
 
-// UnmarshalXML implements the xml.Unmarshaler interface.
-func (r *artifactSearchResponse) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
-	var payload struct {
-		Artifacts []struct {
-			GroupID      string `xml:"groupId"`
-			ArtifactID   string `xml:"artifactId"`
-			Version      string `xml:"version"`
-			ArtifactHits []struct {
-				RepositoryID  string `xml:"repositoryId"`
-				ArtifactLinks []struct {
-					Extension  string `xml:"extension"`
-					Classifier string `xml:"classifier"`
-				} `xml:"artifactLinks>artifactLink"`
-			} `xml:"artifactHits>artifactHit"`
-		} `xml:"data>artifact"`
+// WalkArtifactsContext behaves like WalkArtifacts, but bound to the given
+// context.
+func (nexus Nexus2x) WalkArtifactsContext(ctx context.Context, criteria search.Criteria, fn WalkFunc) error {
+	seen := newArtifactSet() // Nexus sends repeated artifacts; keep track
+
+	err := searchCriteria(ctx, nexus.workers(), nexus.BestEffort, criteria, nexus.searchArtifacts, func(artifacts []*Artifact) error {
+		for _, artifact := range seen.add(artifacts) {
+			if err := fn(artifact); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err == StopWalk {
+		return nil
 	}
 
-	if err := d.DecodeElement(&payload, &start); err != nil {
-		return err
+	return err
+}
+
+// picks the proper way to search with the given parameters, sending the
+// artifacts found to emit.
+func (nexus Nexus2x) searchArtifacts(ctx context.Context, params map[string]string, emit emitFunc) error {
+	if len(params) == 0 { // full search
+		return nexus.fetchAllArtifacts(ctx, emit)
 	}
 
-	var artifacts = []*Artifact{}
+	if len(params) == 1 {
+		if repoID, ok := params["repositoryId"]; ok { // all in repo search
+			return nexus.fetchArtifactsFrom(ctx, repoID, emit)
+		}
+	}
 
-	for _, artifact := range payload.Artifacts {
-		g := artifact.GroupID
-		a := artifact.ArtifactID
-		v := artifact.Version
+	return nexus.fetchArtifactsWhere(ctx, params, emit)
+}
 
-		for _, hit := range artifact.ArtifactHits {
-			r := hit.RepositoryID
-			for _, link := range hit.ArtifactLinks {
-				e := link.Extension
-				c := link.Classifier
+// a GAV in a lucene search page, as Nexus sends it, with the artifacts found
+// in each repository.
+type searchedGAV struct {
+	GroupID      string `xml:"groupId" json:"groupId"`
+	ArtifactID   string `xml:"artifactId" json:"artifactId"`
+	Version      string `xml:"version" json:"version"`
+	ArtifactHits []struct {
+		RepositoryID  string `xml:"repositoryId" json:"repositoryId"`
+		ArtifactLinks []struct {
+			Extension  string `xml:"extension" json:"extension"`
+			Classifier string `xml:"classifier" json:"classifier"`
+		} `xml:"artifactLinks>artifactLink" json:"artifactLinks"`
+	} `xml:"artifactHits>artifactHit" json:"artifactHits"`
+}
 
-				artifacts = append(artifacts, &Artifact{g, a, v, c, e, r})
-			}
+// flattens the GAV into artifacts.
+func (gav searchedGAV) artifacts() []*Artifact {
+	g := gav.GroupID
+	a := gav.ArtifactID
+	v := gav.Version
+
+	artifacts := []*Artifact{}
+	for _, hit := range gav.ArtifactHits {
+		r := hit.RepositoryID
+		for _, link := range hit.ArtifactLinks {
+			e := link.Extension
+			c := link.Classifier
+
+			artifacts = append(artifacts, &Artifact{
+				GroupID:      g,
+				ArtifactID:   a,
+				Version:      v,
+				Classifier:   c,
+				Extension:    e,
+				RepositoryID: r,
+			})
 		}
 	}
 
-	r.Count = len(payload.Artifacts)
-	r.Artifacts = artifacts
-
-	return nil
+	return artifacts
 }
 
 // a slight modification of Go's v, ok := m[key] idiom. has returns false for
@@ -176,122 +365,444 @@ func has(m map[string]string, key string) (value string, ok bool) {
 	return value, ok && value != ""
 }
 
-// returns all artifacts in this Nexus which pass the given filter. The expected
-// keys in filter are the flags Nexus' REST API accepts, with the same
-// semantics.
-func (nexus Nexus2x) fetchArtifactsWhere(filter map[string]string) ([]*Artifact, error) {
-	// This implementation is slightly tricky. As artifactSearchResponse shows,
-	// Nexus always wraps the artifacts in a GAV structure. This structure doesn't
-	// mean that within the wrapper are *all* the artifacts within that GAV, or
-	// that the next page won't repeat artifacts if an incomplete GAV was returned
-	// earlier.
-	//
-	// On top of that, I haven't quite figured out how Nexus is counting artifacts
-	// for paging purposes. POMs don't seem to count as artifacts, except when the
-	// project has a 'pom' packaging (which I can't know for sure without GET-ing
-	// every POM), but the math still didn't quite come together. So I took a
-	// conservative approach, which forces a sequential algorithm. The search
-	// could be parallelized if the paging problem is solved.
+// sends to emit all artifacts in this Nexus which pass the given filter, one
+// page at a time. The expected keys in filter are the flags Nexus' REST API
+// accepts, with the same semantics.
+//
+// Nexus caps the number of results a search may have, and refuses the ones
+// which go over it. These are split into narrower searches (see refine), run
+// in parallel, and split again if needed, until every one of them fits; the
+// repetitions are filtered out downstream. If a search can't be split any
+// further, it fails with a *TooManyResultsError.
+func (nexus Nexus2x) fetchArtifactsWhere(ctx context.Context, filter map[string]string, emit emitFunc) error {
+	err := nexus.fetchPagesWhere(ctx, filter, emit)
+	if _, tooMany := err.(*TooManyResultsError); !tooMany {
+		return err
+	}
 
-	from := 0
-	offset := 0
-	started := false
-	artifacts := newArtifactSet() // accumulates the artifacts
+	key, refined, err2 := nexus.refine(ctx, filter)
+	if err2 != nil {
+		return err2
+	}
 
-	for offset != 0 || !started {
-		started = true // do-while can sometimes be useful :)
+	if len(refined) == 0 { // there's nothing else to do
+		return err
+	}
 
-		from = from + offset
-		filter["from"] = strconv.Itoa(from)
+	return concurrentArtifactSearch(
+		ctx,
+		nexus.workers(),
+		nexus.BestEffort,
+		indicesOf(len(refined)),
+		func(ctx context.Context, i string, emit emitFunc) error {
+			n, _ := strconv.Atoi(i)
+
+			err := nexus.fetchArtifactsWhere(ctx, refined[n], emit)
+			switch {
+			case err == nil:
+				return nil
+			case key == "repositoryId":
+				return asBranchError(err, refined[n]["repositoryId"], "")
+			case key == "a": // the group ID's the same in every branch
+				return asBranchError(err, refined[n]["repositoryId"], refined[n]["g"]+":"+refined[n]["a"])
+			default:
+				return asBranchError(err, refined[n]["repositoryId"], refined[n]["g"])
+			}
+		},
+		emit)
+}
 
-		resp, err := nexus.fetch("service/local/lucene/search", filter)
+// the characters which may follow a prefix in a group or artifact ID, in
+// lower case, since Nexus' search isn't case sensitive.
+const idCharacters = "abcdefghijklmnopqrstuvwxyz0123456789._-"
+
+// returns narrower searches which, together, cover the one filter asks for,
+// and the key they differ in.
+// Searches without a repository are split by repository; otherwise, searches
+// by coordinates are split by extending the group ID's prefix (e.g. org*
+// becomes org, orga*, orgb*, ...), or the artifact ID's, if the group ID is
+// exact. Other searches (e.g. by keyword or class name) can't be split by
+// prefix, since Nexus doesn't combine them with coordinates, and so refine
+// returns none for them.
+//
+// Nexus takes g and a as prefixes (g=org is org*), unless the search is exact,
+// in which case only an asterisk matches anything. Only exact searches can
+// tell a group ID from the ones it prefixes, so the searches returned by
+// prefix are exact, with the original prefixes spelled out.
+func (nexus Nexus2x) refine(ctx context.Context, filter map[string]string) (string, []map[string]string, error) {
+	if _, ok := has(filter, "repositoryId"); !ok {
+		repos, err := nexus.RepositoriesContext(ctx)
 		if err != nil {
-			return nil, err
+			return "", nil, err
 		}
 
-		body, err := bodyToBytes(resp.Body)
-		if err != nil {
-			return nil, err
+		refined := make([]map[string]string, len(repos))
+		for i, repo := range repos {
+			refined[i] = withParameter(filter, "repositoryId", repo.ID)
 		}
 
-		var payload *artifactSearchResponse
-		err = xml.Unmarshal(body, &payload)
-		if err != nil {
-			return nil, err
+		return "repositoryId", refined, nil
+	}
+
+	for _, key := range []string{"q", "cn", "sha1"} {
+		if _, ok := has(filter, key); ok {
+			return "", nil, nil
 		}
+	}
 
-		// extract and store the artifacts, filtering out the POMs if necessary.
-		// The set ensures we ignore repetitions.
-		artifacts.add(filterPoms(payload.Artifacts, filter))
+	exact := withParameter(filter, "exact", "true")
+	if filter["exact"] != "true" {
+		for _, key := range []string{"g", "a"} {
+			if value := filter[key]; value != "" && !strings.HasSuffix(value, "*") {
+				exact[key] = value + "*"
+			}
+		}
+	}
+
+	for _, key := range []string{"g", "a"} {
+		value := exact[key]
+		if value != "" && !strings.HasSuffix(value, "*") { // exact; try the next
+			continue
+		}
 
-		// a lower bound for the number of artifacts returned, since every GAV in
-		// the payload holds at least one artifact. There will be some repetitions,
-		// but artifacts takes care of that.
-		offset = payload.Count
+		prefix := strings.TrimSuffix(value, "*")
+
+		refined := []map[string]string{}
+		if prefix != "" {
+			refined = append(refined, withParameter(exact, key, prefix))
+		}
+
+		for _, c := range idCharacters {
+			refined = append(refined, withParameter(exact, key, prefix+string(c)+"*"))
+		}
+
+		return key, refined, nil
 	}
 
-	return artifacts.data, nil
+	return "", nil, nil
 }
 
-// Nexus 2.x's search always returns the POMs, even when one filters
-// specifically for the packaging or the classifier. So we'll have to take them
-// out here. Of course, if the user specifies "pom", she'll get POMs :)
-// XXX: this function modifies artifacts!
-func filterPoms(artifacts []*Artifact, filter map[string]string) []*Artifact {
-	packaging, okPack := has(filter, "p") // using has: p="" means no packaging
-	_, okClass := has(filter, "c")
+// a copy of filter, with key set to value.
+func withParameter(filter map[string]string, key string, value string) map[string]string {
+	copied := map[string]string{key: value}
+	for k, v := range filter {
+		if k != key {
+			copied[k] = v
+		}
+	}
+
+	return copied
+}
 
-	if (okPack && packaging != "pom") || okClass { // remove the POMs
-		for i := 0; i < len(artifacts); i++ {
-			if artifacts[i].Extension == "pom" {
-				artifacts = append(artifacts[:i], artifacts[i+1:]...)
+// wraps err in a *BranchError for the given repository and prefix, unless it
+// already tells which branches failed.
+func asBranchError(err error, repositoryID string, prefix string) error {
+	switch err.(type) {
+	case *BranchError, *MultiError:
+		return err
+	default:
+		return &BranchError{RepositoryID: repositoryID, Prefix: prefix, Err: err}
+	}
+}
+
+// sends to emit all artifacts in the lucene search pages the given filter asks
+// for.
+func (nexus Nexus2x) fetchPagesWhere(ctx context.Context, filter map[string]string, emit emitFunc) error {
+	// This implementation is slightly tricky. As searchedGAV shows, Nexus always
+	// wraps the artifacts in a GAV structure. This structure doesn't mean that
+	// within the wrapper are *all* the artifacts within that GAV, or that the
+	// next page won't repeat artifacts if an incomplete GAV was returned earlier.
+	//
+	// That's because Nexus doesn't page by GAV, but by artifact, and POMs don't
+	// count, unless nothing else in their GAV and repository matched (e.g. a
+	// project with a 'pom' packaging). The POMs which don't count come along
+	// with the first artifact of their GAV which does. totalCount, from and
+	// count are in these units, so once the first page arrives, the others can
+	// be fetched in parallel; the repetitions from split GAVs are filtered out
+	// downstream.
+	//
+	// If the counts don't add up, though (e.g. they're missing, or the index
+	// changed in the middle of the search), the paging falls back to a
+	// conservative, sequential approach, which only relies on every GAV holding
+	// at least one artifact that counts.
+	first, err := nexus.fetchSearchPage(ctx, filter, 0, emit)
+	if err != nil {
+		return err
+	}
+
+	if !first.consistent(0) {
+		return nexus.fetchSearchPagesFrom(ctx, filter, first.gavs, emit)
+	}
+
+	froms := []string{}
+	for from := first.count; from < first.totalCount; from += first.count {
+		froms = append(froms, strconv.Itoa(from))
+	}
+
+	err = concurrentArtifactSearch(
+		ctx,
+		nexus.workers(),
+		false, // a page can't fail on its own
+		froms,
+		func(ctx context.Context, datum string, emit emitFunc) error {
+			from, _ := strconv.Atoi(datum)
+
+			page, err := nexus.fetchSearchPage(ctx, filter, from, emit)
+			if err != nil {
+				return err
 			}
+
+			if !page.consistent(from) || page.totalCount != first.totalCount {
+				return errInconsistentPaging
+			}
+
+			return nil
+		},
+		emit)
+
+	if err == errInconsistentPaging { // start over, the conservative way
+		return nexus.fetchSearchPagesFrom(ctx, filter, 0, emit)
+	}
+
+	return err
+}
+
+// signals that the lucene search's counts don't add up.
+var errInconsistentPaging = errors.New("inconsistent paging")
+
+// sends to emit all artifacts in the lucene search pages the given filter asks
+// for, starting at from, one page at a time. Every page starts after as many
+// artifacts as the previous page had GAVs, which is a lower bound for the
+// number of artifacts it had.
+func (nexus Nexus2x) fetchSearchPagesFrom(ctx context.Context, filter map[string]string, from int, emit emitFunc) error {
+	for {
+		page, err := nexus.fetchSearchPage(ctx, filter, from, emit)
+		if err != nil {
+			return err
+		}
+
+		if page.gavs == 0 {
+			return nil
 		}
+
+		from += page.gavs
 	}
+}
 
-	return artifacts
+// the paging information of a lucene search page. Apart from gavs, it's in
+// the units Nexus pages by (see fetchArtifactsWhere).
+type searchPage struct {
+	gavs           int  // how many GAVs the page held
+	counted        bool // if Nexus sent the counts below
+	totalCount     int  // how many artifacts matched the search
+	from           int  // the first artifact in the page
+	count          int  // the page size
+	tooManyResults bool // if Nexus refused the search
 }
 
-// returns the first-level directories in the given repository.
-func (nexus Nexus2x) fetchFirstLevelDirsOf(repositoryID string) ([]string, error) {
-	// XXX Don't forget the ending /!
-	resp, err := nexus.fetch("service/local/repositories/"+repositoryID+"/content/", nil)
+// reads the paging information from the given fields of a lucene page.
+func newSearchPage(fields map[string]string, gavs int) searchPage {
+	page := searchPage{gavs: gavs, tooManyResults: fields["tooManyResults"] == "true"}
+
+	var errs [3]error
+	page.totalCount, errs[0] = strconv.Atoi(fields["totalCount"])
+	page.from, errs[1] = strconv.Atoi(fields["from"])
+	page.count, errs[2] = strconv.Atoi(fields["count"])
+	page.counted = errs[0] == nil && errs[1] == nil && errs[2] == nil
+
+	return page
+}
+
+// if the page's counts add up, for a page which should start at from. A page
+// holds every GAV with an artifact in it, so there can't be more GAVs than
+// artifacts, and there must be some if there are artifacts.
+func (page searchPage) consistent(from int) bool {
+	if !page.counted || page.from != from || page.count < 1 || page.totalCount < 0 {
+		return false
+	}
+
+	artifacts := page.totalCount - from
+	if artifacts > page.count {
+		artifacts = page.count
+	}
+
+	if artifacts <= 0 {
+		return page.gavs == 0
+	}
+
+	return 0 < page.gavs && page.gavs <= artifacts
+}
+
+// how many results a lucene search page asks for; Nexus' own default. The
+// pages are fetched by the size Nexus says it used, though.
+const searchPageSize = 200
+
+// sends to emit the artifacts in the lucene search page the given filter asks
+// for, starting at from, one GAV at a time, as they're read. The page is read
+// apart from emit (see emitAsRead), so its request's Limiter slot is freed as
+// soon as it's all in, however long emit takes. If Nexus refuses the search,
+// it fails with a *TooManyResultsError.
+func (nexus Nexus2x) fetchSearchPage(ctx context.Context, filter map[string]string, from int, emit emitFunc) (searchPage, error) {
+	// pages may run in parallel. Without an explicit count, Nexus sends -1 as
+	// the page size, and the paging can't be worked out
+	query := map[string]string{"from": strconv.Itoa(from), "count": strconv.Itoa(searchPageSize)}
+	for key, value := range filter {
+		if key != "from" && key != "count" {
+			query[key] = value
+		}
+	}
+
+	// if emit stops early, the rest of the page isn't wanted anymore; cancelling
+	// its request closes the body, and frees its Limiter slot right away
+	readCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	resp, err := nexus.fetch(readCtx, "service/local/lucene/search", query)
 	if err != nil {
-		return nil, err
+		return searchPage{}, err
 	}
 
-	// fill payload with the given response
-	body, err := bodyToBytes(resp.Body)
+	gavs := 0
+	fields := map[string]string{}
+	poms := pomsFilterFor(filter)
+	err = emitAsRead(func(emit emitFunc) error {
+		defer resp.Body.Close()
+
+		return nexus.Format.eachInData(resp.Body, "artifact", fields, func(decode decodeFunc) error {
+			var gav searchedGAV
+			if err := decode(&gav); err != nil {
+				return err
+			}
+
+			gavs++
+
+			// pass the artifacts along, filtering out the POMs if necessary.
+			// Repetitions are dealt with downstream.
+			return emit(keep(gav.artifacts(), poms))
+		})
+	}, emit)
+
 	if err != nil {
-		return nil, err
+		return searchPage{}, err
 	}
 
-	var payload *struct {
-		Data []struct {
-			Leaf bool   `xml:"leaf"`
-			Text string `xml:"text"`
-		} `xml:"data>content-item"`
+	page := newSearchPage(fields, gavs)
+	if page.tooManyResults {
+		return page, &TooManyResultsError{URL: nexus.URL, Parameters: filter}
+	}
+
+	return page, nil
+}
+
+// sends to emit the batches of artifacts which read sends, as they arrive. read runs
+// in its own goroutine, and never waits for emit, so it gets to the end of its
+// response (freeing its Limiter slot) even if emit makes requests of its own
+// through the same Limiter. If emit fails, this function returns right away,
+// and it's up to the caller to stop read (e.g. by cancelling its request);
+// otherwise, everything read wrote is visible when this function returns.
+func emitAsRead(read func(emit emitFunc) error, emit emitFunc) error {
+	var mutex sync.Mutex
+	queued := [][]*Artifact{}
+	done := false
+	var readErr error
+
+	ready := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case ready <- empty:
+		default: // there's a notification pending already
+		}
+	}
+
+	go func() {
+		err := read(func(artifacts []*Artifact) error {
+			mutex.Lock()
+			queued = append(queued, artifacts)
+			mutex.Unlock()
+
+			notify()
+			return nil
+		})
+
+		mutex.Lock()
+		done, readErr = true, err
+		mutex.Unlock()
+
+		notify()
+	}()
+
+	for {
+		<-ready
+
+		mutex.Lock()
+		batches, finished, err := queued, done, readErr
+		queued = nil
+		mutex.Unlock()
+
+		for _, artifacts := range batches {
+			if err := emit(artifacts); err != nil {
+				return err
+			}
+		}
+
+		if finished {
+			return err
+		}
+	}
+}
+
+// Nexus 2.x's search always returns the POMs, even when one filters
+// specifically for the packaging or the classifier. So they're taken out with
+// the predicate returned here, or nil if the filter is fine with them. Of
+// course, if the user specifies "pom", she'll get POMs :)
+func pomsFilterFor(filter map[string]string) search.Predicate {
+	packaging, okPack := has(filter, "p") // using has: p="" means no packaging
+	_, okClass := has(filter, "c")
+
+	if (okPack && packaging != "pom") || okClass {
+		return search.NoneOf{search.ExtensionIn{"pom"}}
 	}
 
-	err = xml.Unmarshal(body, &payload)
+	return nil
+}
+
+// returns the first-level directories in the given repository.
+func (nexus Nexus2x) fetchFirstLevelDirsOf(ctx context.Context, repositoryID string) ([]string, error) {
+	// XXX Don't forget the ending /!
+	resp, err := nexus.fetch(ctx, "service/local/repositories/"+repositoryID+"/content/", nil)
 	if err != nil {
 		return nil, err
 	}
+	defer resp.Body.Close()
 
-	// extract the directories from payload
+	// extract the directories from the listing, as it's read
 	result := []string{}
-	for _, dir := range payload.Data {
-		if !dir.Leaf {
-			result = append(result, dir.Text)
+	err = nexus.Format.eachInData(resp.Body, "content-item", nil, func(decode decodeFunc) error {
+		var item struct {
+			Leaf bool   `xml:"leaf" json:"leaf"`
+			Text string `xml:"text" json:"text"`
+		}
+
+		if err := decode(&item); err != nil {
+			return err
 		}
+
+		if !item.Leaf {
+			result = append(result, item.Text)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
 	}
 
 	return result, nil
-
 }
 
-// returns all artifacts in the given repository.
-func (nexus Nexus2x) fetchArtifactsFrom(repositoryID string) ([]*Artifact, error) {
+// sends to emit all artifacts in the given repository.
+func (nexus Nexus2x) fetchArtifactsFrom(ctx context.Context, repositoryID string, emit emitFunc) error {
 	// This function also has some tricky details. In the olden days (around
 	// version 1.8 or so), one could get all the artifacts in a given repository
 	// by searching for *. This has been disabled in the newer versions, without
@@ -300,31 +811,40 @@ func (nexus Nexus2x) fetchArtifactsFrom(repositoryID string) ([]*Artifact, error
 	// 1) get the first level directories in repositoryID
 	// 2) for every directory 'dir', do a search filtering for the groupID 'dir*'
 	//    and the repository ID
-	// 3) accumulate the results in an artifactSet to avoid duplicates (e.g. the
+	// 3) pass the results along; the walk filters out the duplicates (e.g. the
 	//    results in common* appear also in com*)
 
 	// 1)
-	dirs, err := nexus.fetchFirstLevelDirsOf(repositoryID)
+	dirs, err := nexus.fetchFirstLevelDirsOf(ctx, repositoryID)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	// 2) and 3)
 	return concurrentArtifactSearch(
+		ctx,
+		nexus.workers(),
+		nexus.BestEffort,
 		dirs,
-		func(datum string) ([]*Artifact, error) {
-			return nexus.fetchArtifactsWhere(
-				map[string]string{"g": datum + "*", "repositoryId": repositoryID})
-		})
+		func(ctx context.Context, datum string, emit emitFunc) error {
+			err := nexus.fetchArtifactsWhere(
+				ctx, map[string]string{"g": datum + "*", "repositoryId": repositoryID}, emit)
+			if err != nil {
+				return asBranchError(err, repositoryID, datum+"*")
+			}
+
+			return nil
+		},
+		emit)
 }
 
-// returns all artifacts visible by this Nexus.
-func (nexus Nexus2x) fetchAllArtifacts() ([]*Artifact, error) {
+// sends to emit all artifacts visible by this Nexus.
+func (nexus Nexus2x) fetchAllArtifacts(ctx context.Context, emit emitFunc) error {
 	// there's no easy way to do this, so get the repos and search for all
 	// artifacts in each one (yup)
-	repos, err := nexus.Repositories()
+	repos, err := nexus.RepositoriesContext(ctx)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	// all we need for the search is the IDs
@@ -334,37 +854,49 @@ func (nexus Nexus2x) fetchAllArtifacts() ([]*Artifact, error) {
 	}
 
 	return concurrentArtifactSearch(
+		ctx,
+		nexus.workers(),
+		nexus.BestEffort,
 		ids,
-		func(datum string) ([]*Artifact, error) {
-			return nexus.fetchArtifactsFrom(datum)
-		})
+		func(ctx context.Context, datum string, emit emitFunc) error {
+			err := nexus.fetchArtifactsFrom(ctx, datum, emit)
+			if err != nil { // maybe the repository itself failed
+				return asBranchError(err, datum, "")
+			}
+
+			return nil
+		},
+		emit)
 }
 
 // InfoOf implements the Client interface, fetching extra information about the
 // given artifact.
 func (nexus Nexus2x) InfoOf(artifact *Artifact) (*ArtifactInfo, error) {
+	return nexus.InfoOfContext(context.Background(), artifact)
+}
+
+// InfoOfContext implements the Client interface, behaving like InfoOf, but
+// bound to the given context.
+func (nexus Nexus2x) InfoOfContext(ctx context.Context, artifact *Artifact) (*ArtifactInfo, error) {
 	// first resolve the artifact: building the URL by hand may fail in some
 	// situations (e.g. snapshot artifacts, odd file names)
-	path, err := nexus.fetchRepositoryPathOf(artifact)
+	path, err := nexus.fetchRepositoryPathOf(ctx, artifact)
 	if err != nil {
 		return nil, err
 	}
 
-	// now we can reliably build the proper URL
-	resp, err := nexus.fetch(
-		"service/local/repositories/"+artifact.RepositoryID+"/content"+path,
-		map[string]string{"describe": "info"})
-	if err != nil {
-		return nil, err
-	}
-
-	body, err := bodyToBytes(resp.Body)
-	if err != nil {
-		return nil, err
-	}
+	return nexus.fetchInfoAt(ctx, artifact, path)
+}
 
+// fetches the information about the given artifact, which is at the given
+// path in its repository.
+func (nexus Nexus2x) fetchInfoAt(ctx context.Context, artifact *Artifact, path string) (*ArtifactInfo, error) {
 	payload := newInfoFromArtifact(artifact)
-	err = xml.Unmarshal(body, &payload)
+	err := nexus.fetchInto(
+		ctx,
+		"service/local/repositories/"+artifact.RepositoryID+"/content"+path,
+		map[string]string{"describe": "info"},
+		&payload)
 	if err != nil {
 		return nil, err
 	}
@@ -372,8 +904,14 @@ func (nexus Nexus2x) InfoOf(artifact *Artifact) (*ArtifactInfo, error) {
 	return payload, nil
 }
 
-func (nexus Nexus2x) fetchRepositoryPathOf(artifact *Artifact) (string, error) {
-	resp, err := nexus.fetch("service/local/artifact/maven/resolve",
+func (nexus Nexus2x) fetchRepositoryPathOf(ctx context.Context, artifact *Artifact) (string, error) {
+	var payload *struct {
+		Data struct {
+			RepositoryPath string `xml:"repositoryPath" json:"repositoryPath"`
+		} `xml:"data" json:"data"`
+	}
+
+	err := nexus.fetchInto(ctx, "service/local/artifact/maven/resolve",
 		map[string]string{
 			"g": artifact.GroupID,
 			"a": artifact.ArtifactID,
@@ -381,48 +919,136 @@ func (nexus Nexus2x) fetchRepositoryPathOf(artifact *Artifact) (string, error) {
 			"e": artifact.Extension,
 			"c": artifact.Classifier,
 			"r": artifact.RepositoryID,
-		})
+		},
+		&payload)
 	if err != nil {
 		return "", err
 	}
 
-	body, err := bodyToBytes(resp.Body)
+	return payload.Data.RepositoryPath, nil
+}
+
+// Repositories implements the Client interface, returning all repositories in
+// this Nexus.
+func (nexus Nexus2x) Repositories() ([]*Repository, error) {
+	return nexus.RepositoriesContext(context.Background())
+}
+
+// RepositoriesContext implements the Client interface, behaving like
+// Repositories, but bound to the given context.
+func (nexus Nexus2x) RepositoriesContext(ctx context.Context) ([]*Repository, error) {
+	var payload *repos
+	err := nexus.fetchInto(ctx, "service/local/repositories", nil, &payload)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	var payload *struct {
-		Data struct {
-			RepositoryPath string `xml:"repositoryPath"`
-		} `xml:"data"`
+	return *payload, nil
+}
+
+// RepositoryInfo returns the detailed settings of the repository with the
+// given ID. For proxy repositories, it also fetches the remote status.
+func (nexus Nexus2x) RepositoryInfo(id string) (*RepositoryInfo, error) {
+	return nexus.RepositoryInfoContext(context.Background(), id)
+}
+
+// RepositoryInfoContext behaves like RepositoryInfo, but bound to the given
+// context.
+func (nexus Nexus2x) RepositoryInfoContext(ctx context.Context, id string) (*RepositoryInfo, error) {
+	payload := &RepositoryInfo{}
+	err := nexus.fetchInto(ctx, "service/local/repositories/"+id, nil, &payload)
+	if err != nil {
+		return nil, err
 	}
 
-	err = xml.Unmarshal(body, &payload)
+	if payload.Type != "proxy" { // the rest is about the remote
+		return payload, nil
+	}
+
+	var status *repositoryStatus
+	err = nexus.fetchInto(ctx, "service/local/repositories/"+id+"/status", nil, &status)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return payload.Data.RepositoryPath, nil
+	payload.RemoteStatus = status.Data.RemoteStatus
+	payload.ProxyMode = status.Data.ProxyMode
+
+	return payload, nil
 }
 
-// Repositories implements the Client interface, returning all repositories in
-// this Nexus.
-func (nexus Nexus2x) Repositories() ([]*Repository, error) {
-	resp, err := nexus.fetch("service/local/repositories", nil)
+// Groups returns all repository groups in this Nexus.
+func (nexus Nexus2x) Groups() ([]*Group, error) {
+	return nexus.GroupsContext(context.Background())
+}
+
+// GroupsContext behaves like Groups, but bound to the given context.
+func (nexus Nexus2x) GroupsContext(ctx context.Context) ([]*Group, error) {
+	var payload *groups
+	err := nexus.fetchInto(ctx, "service/local/repo_groups", nil, &payload)
 	if err != nil {
 		return nil, err
 	}
 
-	body, err := bodyToBytes(resp.Body)
+	return *payload, nil
+}
+
+// MembersOf returns the repositories in the given group, in the group's
+// order. Groups nested in the given one are expanded in place, and
+// repositories reachable more than once appear only the first time.
+func (nexus Nexus2x) MembersOf(group *Group) ([]*Repository, error) {
+	return nexus.MembersOfContext(context.Background(), group)
+}
+
+// MembersOfContext behaves like MembersOf, but bound to the given context.
+func (nexus Nexus2x) MembersOfContext(ctx context.Context, group *Group) ([]*Repository, error) {
+	repos, err := nexus.RepositoriesContext(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	var payload *repos
-	err = xml.Unmarshal(body, &payload)
+	groups, err := nexus.GroupsContext(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	return *payload, nil
+	return expandGroup(group, repos, groups), nil
+}
+
+// flattens the given group into its member repositories, using the given
+// repositories and groups to look the member IDs up.
+func expandGroup(group *Group, repos []*Repository, groups []*Group) []*Repository {
+	reposByID := map[string]*Repository{}
+	for _, repo := range repos {
+		reposByID[repo.ID] = repo
+	}
+
+	groupsByID := map[string]*Group{}
+	for _, g := range groups {
+		groupsByID[g.ID] = g
+	}
+
+	result := []*Repository{}
+	seen := map[string]bool{} // avoids repetitions, and cycles too
+
+	var expand func(g *Group)
+	expand = func(g *Group) {
+		for _, id := range g.MemberIDs {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+
+			if repo, ok := reposByID[id]; ok {
+				result = append(result, repo)
+			} else if nested, ok := groupsByID[id]; ok {
+				expand(nested)
+			}
+		}
+	}
+
+	seen[group.ID] = true
+	expand(group)
+
+	return result
 }
@@ -0,0 +1,455 @@
+package nexus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"sbrubbles.org/go/nexus/credentials"
+	"sbrubbles.org/go/nexus/search"
+	"sbrubbles.org/go/nexus/util"
+)
+
+// Nexus3x represents a Nexus v3.x instance. Nexus 3 dropped the
+// /service/local API altogether, so it needs its own Client, built on top of
+// the /service/rest/v1 API.
+//
+// Nexus 3's search doesn't map perfectly to Nexus 2's. Packaging is searched
+// as the extension, and there's no search by class name. Nexus 3 matches
+// group and artifact IDs exactly, so they get a trailing * to be taken as
+// prefixes, like Nexus 2 does.
+type Nexus3x struct {
+	URL            string                  // e.g. http://somewhere.com:8081
+	Credentials    credentials.Credentials // e.g. credentials.BasicAuth("u", "p")
+	HTTPClient     *http.Client            // the network client
+	MaxParallelism int                     // e.g. 4; DefaultMaxParallelism if < 1
+}
+
+// New3x creates a new Nexus client for a Nexus v3.x instance.
+func New3x(url string, c credentials.Credentials) Client {
+	return &Nexus3x{
+		URL:         url,
+		Credentials: credentials.OrZero(c),
+		HTTPClient:  &http.Client{}}
+}
+
+// the number of parallel queries this client runs in a full search.
+func (nexus Nexus3x) workers() int {
+	if nexus.MaxParallelism < 1 {
+		return DefaultMaxParallelism
+	}
+
+	return nexus.MaxParallelism
+}
+
+// goes to Nexus and unmarshals the JSON response in payload.
+func (nexus Nexus3x) fetch(ctx context.Context, path string, query map[string]string, payload interface{}) error {
+	fullURL, err := util.BuildFullURL(nexus.URL, path, query)
+	if err != nil {
+		return err
+	}
+
+	get, err := http.NewRequestWithContext(ctx, "GET", fullURL, nil)
+	if err != nil {
+		return err
+	}
+
+	nexus.Credentials.Sign(get)
+
+	// Nexus 3's REST API speaks only JSON
+	get.Header.Add("Accept", "application/json")
+
+	response, err := nexus.HTTPClient.Do(get)
+	if err != nil {
+		return err
+	}
+
+	status := response.StatusCode
+	switch {
+	case status == http.StatusUnauthorized:
+		response.Body.Close()
+		return &credentials.Error{URL: fullURL, Credentials: nexus.Credentials}
+	case 400 <= status && status < 600:
+		return errorFromResponse(nexus.URL, response)
+	}
+
+	defer response.Body.Close()
+
+	return json.NewDecoder(response.Body).Decode(payload)
+}
+
+// Nexus 3's search parameters for each of Nexus 2's.
+var nexus3Parameters = map[string]string{
+	"g":            "maven.groupId",
+	"a":            "maven.artifactId",
+	"v":            "maven.baseVersion",
+	"c":            "maven.classifier",
+	"p":            "maven.extension",
+	"q":            "q",
+	"sha1":         "sha1",
+	"repositoryId": "repository",
+}
+
+// translates the given search.Criteria parameters to Nexus 3's.
+func nexus3QueryFor(params map[string]string) (map[string]string, error) {
+	query := map[string]string{"format": "maven2"}
+
+	for key, value := range params {
+		key3, ok := nexus3Parameters[key]
+		if !ok {
+			return nil, fmt.Errorf("Nexus 3 can't search by %q", key)
+		}
+
+		// Nexus 2 takes g and a as prefixes
+		if (key == "g" || key == "a") && !strings.HasSuffix(value, "*") {
+			value += "*"
+		}
+
+		query[key3] = value
+	}
+
+	return query, nil
+}
+
+// an asset, as Nexus 3 describes it.
+type asset3 struct {
+	DownloadURL  string            `json:"downloadUrl"`
+	Repository   string            `json:"repository"`
+	ContentType  string            `json:"contentType"`
+	LastModified string            `json:"lastModified"`
+	Uploader     string            `json:"uploader"`
+	FileSize     int64             `json:"fileSize"`
+	Checksum     map[string]string `json:"checksum"`
+	Maven2       struct {
+		GroupID    string `json:"groupId"`
+		ArtifactID string `json:"artifactId"`
+		Version    string `json:"version"`
+		Classifier string `json:"classifier"`
+		Extension  string `json:"extension"`
+	} `json:"maven2"`
+}
+
+// the artifact this asset is, or nil if it isn't one (e.g. a checksum file or
+// maven-metadata.xml).
+func (a asset3) artifact() *Artifact {
+	m := a.Maven2
+	if m.ArtifactID == "" || m.Version == "" || m.Extension == "" {
+		return nil
+	}
+
+	// Nexus 3 lists the checksums and signatures as assets too
+	for _, suffix := range []string{"md5", "sha1", "sha256", "sha512", "asc"} {
+		if m.Extension == suffix || strings.HasSuffix(m.Extension, "."+suffix) {
+			return nil
+		}
+	}
+
+	return &Artifact{
+		GroupID:      m.GroupID,
+		ArtifactID:   m.ArtifactID,
+		Version:      m.Version,
+		Classifier:   m.Classifier,
+		Extension:    m.Extension,
+		RepositoryID: a.Repository,
+	}
+}
+
+// the artifacts among the given assets.
+func artifactsIn(assets []asset3) []*Artifact {
+	artifacts := []*Artifact{}
+
+	for _, a := range assets {
+		if artifact := a.artifact(); artifact != nil {
+			artifacts = append(artifacts, artifact)
+		}
+	}
+
+	return artifacts
+}
+
+// Artifacts implements the Client interface, returning all artifacts in this
+// Nexus which satisfy the given criteria. Nil is the same as search.All. If no
+// criteria are given (e.g. search.All), it does a full search in all
+// repositories, with the same caveats as Nexus2x.Artifacts.
+func (nexus Nexus3x) Artifacts(criteria search.Criteria) ([]*Artifact, error) {
+	return nexus.ArtifactsContext(context.Background(), criteria)
+}
+
+// ArtifactsContext implements the Client interface, behaving like Artifacts,
+// but bound to the given context.
+func (nexus Nexus3x) ArtifactsContext(ctx context.Context, criteria search.Criteria) ([]*Artifact, error) {
+	artifacts := []*Artifact{}
+
+	err := nexus.WalkArtifactsContext(ctx, criteria, func(artifact *Artifact) error {
+		artifacts = append(artifacts, artifact)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return artifacts, nil
+}
+
+// WalkArtifacts behaves like Nexus2x.WalkArtifacts, calling fn for each
+// artifact as soon as the page holding it is fetched.
+func (nexus Nexus3x) WalkArtifacts(criteria search.Criteria, fn WalkFunc) error {
+	return nexus.WalkArtifactsContext(context.Background(), criteria, fn)
+}
+
+// WalkArtifactsContext behaves like WalkArtifacts, but bound to the given
+// context.
+func (nexus Nexus3x) WalkArtifactsContext(ctx context.Context, criteria search.Criteria, fn WalkFunc) error {
+	seen := newArtifactSet()
+
+	err := searchCriteria(ctx, nexus.workers(), false, criteria, nexus.searchArtifacts, func(artifacts []*Artifact) error {
+		for _, artifact := range seen.add(artifacts) {
+			if err := fn(artifact); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err == StopWalk {
+		return nil
+	}
+
+	return err
+}
+
+// picks the proper way to search with the given parameters, sending the
+// artifacts found to emit.
+func (nexus Nexus3x) searchArtifacts(ctx context.Context, params map[string]string, emit emitFunc) error {
+	if len(params) == 0 { // full search
+		return nexus.fetchAllArtifacts(ctx, emit)
+	}
+
+	if len(params) == 1 {
+		if repoID, ok := params["repositoryId"]; ok { // all in repo search
+			return nexus.fetchArtifactsFrom(ctx, repoID, emit)
+		}
+	}
+
+	query, err := nexus3QueryFor(params)
+	if err != nil {
+		return err
+	}
+
+	return nexus.fetchPages(ctx, "service/rest/v1/search/assets", query,
+		func(body []byte) (string, []*Artifact, error) {
+			var payload struct {
+				Items             []asset3 `json:"items"`
+				ContinuationToken string   `json:"continuationToken"`
+			}
+
+			if err := json.Unmarshal(body, &payload); err != nil {
+				return "", nil, err
+			}
+
+			return payload.ContinuationToken, artifactsIn(payload.Items), nil
+		},
+		emit)
+}
+
+// Nexus 3 pages its listings with continuation tokens. This function follows
+// them until the last page, sending the artifacts of each page to emit. parse
+// extracts the token for the next page ("" if there's none) and the artifacts
+// from a page.
+func (nexus Nexus3x) fetchPages(ctx context.Context, path string, query map[string]string,
+	parse func(body []byte) (string, []*Artifact, error), emit emitFunc) error {
+	for {
+		var body json.RawMessage
+		if err := nexus.fetch(ctx, path, query, &body); err != nil {
+			return err
+		}
+
+		token, artifacts, err := parse(body)
+		if err != nil {
+			return err
+		}
+
+		if err := emit(artifacts); err != nil {
+			return err
+		}
+
+		if token == "" {
+			return nil
+		}
+
+		query["continuationToken"] = token
+	}
+}
+
+// sends to emit all artifacts in the given repository.
+func (nexus Nexus3x) fetchArtifactsFrom(ctx context.Context, repositoryID string, emit emitFunc) error {
+	return nexus.fetchPages(ctx, "service/rest/v1/components",
+		map[string]string{"repository": repositoryID},
+		func(body []byte) (string, []*Artifact, error) {
+			var payload struct {
+				Items []struct {
+					Assets []asset3 `json:"assets"`
+				} `json:"items"`
+				ContinuationToken string `json:"continuationToken"`
+			}
+
+			if err := json.Unmarshal(body, &payload); err != nil {
+				return "", nil, err
+			}
+
+			artifacts := []*Artifact{}
+			for _, component := range payload.Items {
+				artifacts = append(artifacts, artifactsIn(component.Assets)...)
+			}
+
+			return payload.ContinuationToken, artifacts, nil
+		},
+		emit)
+}
+
+// sends to emit all artifacts visible by this Nexus.
+func (nexus Nexus3x) fetchAllArtifacts(ctx context.Context, emit emitFunc) error {
+	repos, err := nexus.RepositoriesContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	ids := []string{}
+	for _, repo := range repos {
+		if repo.Format == "maven2" {
+			ids = append(ids, repo.ID)
+		}
+	}
+
+	return concurrentArtifactSearch(
+		ctx,
+		nexus.workers(),
+		false,
+		ids,
+		func(ctx context.Context, datum string, emit emitFunc) error {
+			return nexus.fetchArtifactsFrom(ctx, datum, emit)
+		},
+		emit)
+}
+
+// InfoOf implements the Client interface, fetching extra information about the
+// given artifact.
+func (nexus Nexus3x) InfoOf(artifact *Artifact) (*ArtifactInfo, error) {
+	return nexus.InfoOfContext(context.Background(), artifact)
+}
+
+// InfoOfContext implements the Client interface, behaving like InfoOf, but
+// bound to the given context.
+func (nexus Nexus3x) InfoOfContext(ctx context.Context, artifact *Artifact) (*ArtifactInfo, error) {
+	// the artifacts found have the assets' versions, which are timestamped
+	// for snapshots; maven.baseVersion would be X-SNAPSHOT
+	query := map[string]string{
+		"format":           "maven2",
+		"repository":       artifact.RepositoryID,
+		"maven.groupId":    artifact.GroupID,
+		"maven.artifactId": artifact.ArtifactID,
+		"version":          artifact.Version,
+		"maven.extension":  artifact.Extension,
+	}
+
+	if artifact.Classifier != "" {
+		query["maven.classifier"] = artifact.Classifier
+	}
+
+	for {
+		var payload struct {
+			Items             []asset3 `json:"items"`
+			ContinuationToken string   `json:"continuationToken"`
+		}
+
+		if err := nexus.fetch(ctx, "service/rest/v1/search/assets", query, &payload); err != nil {
+			return nil, err
+		}
+
+		// the search may bring more than needed (e.g. other classifiers), so
+		// look for the exact match
+		for _, a := range payload.Items {
+			if found := a.artifact(); found != nil && hashOf(found) == hashOf(artifact) {
+				return a.infoOf(artifact), nil
+			}
+		}
+
+		if payload.ContinuationToken == "" {
+			return nil, &Error{
+				URL:        nexus.URL,
+				StatusCode: http.StatusNotFound,
+				Status:     "404 Not Found",
+				Message:    fmt.Sprintf("%v not found in %v", artifact, nexus.URL),
+			}
+		}
+
+		query["continuationToken"] = payload.ContinuationToken
+	}
+}
+
+// the extra information this asset holds about the given artifact.
+func (a asset3) infoOf(artifact *Artifact) *ArtifactInfo {
+	info := newInfoFromArtifact(artifact)
+
+	// Nexus 3 uses ISO 8601 timestamps, and older versions don't send it
+	lastChanged, err := time.Parse(time.RFC3339, a.LastModified)
+	if err == nil {
+		info.LastChanged = lastChanged
+		info.Uploaded = lastChanged
+	}
+
+	info.Uploader = a.Uploader
+	info.Sha1 = a.Checksum["sha1"]
+	info.Size = util.ByteSize(a.FileSize)
+	info.MimeType = a.ContentType
+	info.URL = a.DownloadURL
+
+	return info
+}
+
+// Repositories implements the Client interface, returning all repositories in
+// this Nexus. Groups are left out, as in Nexus2x.Repositories.
+func (nexus Nexus3x) Repositories() ([]*Repository, error) {
+	return nexus.RepositoriesContext(context.Background())
+}
+
+// RepositoriesContext implements the Client interface, behaving like
+// Repositories, but bound to the given context.
+func (nexus Nexus3x) RepositoriesContext(ctx context.Context) ([]*Repository, error) {
+	var payload []struct {
+		Name       string `json:"name"`
+		Format     string `json:"format"`
+		Type       string `json:"type"`
+		Attributes struct {
+			Proxy struct {
+				RemoteURL string `json:"remoteUrl"`
+			} `json:"proxy"`
+		} `json:"attributes"`
+	}
+
+	if err := nexus.fetch(ctx, "service/rest/v1/repositories", nil, &payload); err != nil {
+		return nil, err
+	}
+
+	result := []*Repository{}
+	for _, repo := range payload {
+		if repo.Type == "group" {
+			continue
+		}
+
+		// Nexus 3 has no name apart from the ID, and doesn't say the policy here
+		result = append(result, &Repository{
+			ID:        repo.Name,
+			Name:      repo.Name,
+			Type:      repo.Type,
+			Format:    repo.Format,
+			RemoteURI: repo.Attributes.Proxy.RemoteURL,
+		})
+	}
+
+	return result, nil
+}
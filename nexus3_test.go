@@ -0,0 +1,192 @@
+package nexus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"sbrubbles.org/go/nexus/credentials"
+	"sbrubbles.org/go/nexus/search"
+	"sbrubbles.org/go/nexus/util"
+)
+
+func TestNexus3xImplementsClient(t *testing.T) {
+	if _, ok := interface{}(Nexus3x{}).(Client); !ok {
+		t.Errorf("nexus.Nexus3x does not implement nexus.Client!")
+	}
+}
+
+func TestNexus3QueryForTranslatesTheParameters(t *testing.T) {
+	actual, err := nexus3QueryFor(search.InRepository{
+		RepositoryID: "releases",
+		Criteria:     search.ByCoordinates{GroupID: "g*", ArtifactID: "a", Version: "v", Classifier: "c", Packaging: "p"},
+	}.Parameters())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := map[string]string{
+		"format":            "maven2",
+		"repository":        "releases",
+		"maven.groupId":     "g*",
+		"maven.artifactId":  "a*",
+		"maven.baseVersion": "v",
+		"maven.classifier":  "c",
+		"maven.extension":   "p",
+	}
+
+	diff, onlyExpected, onlyActual := util.MapDiff(expected, actual)
+	if len(diff)+len(onlyExpected)+len(onlyActual) != 0 {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+}
+
+func TestNexus3xSearchesGroupAndArtifactIDsAsPrefixes(t *testing.T) {
+	var query map[string][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		w.Write([]byte(`{"items": []}`))
+	}))
+	defer server.Close()
+
+	_, err := New3x(server.URL, credentials.None).Artifacts(search.ByCoordinates{GroupID: "com.acme", ArtifactID: "app", Version: "1.0"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for key, expected := range map[string]string{"maven.groupId": "com.acme*", "maven.artifactId": "app*", "maven.baseVersion": "1.0"} {
+		if len(query[key]) != 1 || query[key][0] != expected {
+			t.Errorf("Expected %v=%v, got %v", key, expected, query[key])
+		}
+	}
+}
+
+func TestNexus3QueryForRefusesClassnames(t *testing.T) {
+	if _, err := nexus3QueryFor(search.ByClassname("java.lang.Object").Parameters()); err == nil {
+		t.Errorf("Expected an error!")
+	}
+}
+
+const assets3Page1 = `{
+  "items": [
+    {"downloadUrl": "http://nexus/repository/releases/g/a/1/a-1.jar", "repository": "releases",
+     "contentType": "application/java-archive", "lastModified": "2020-01-02T03:04:05Z",
+     "checksum": {"sha1": "abc"},
+     "maven2": {"groupId": "g", "artifactId": "a", "version": "1", "extension": "jar"}},
+    {"repository": "releases",
+     "maven2": {"groupId": "g", "artifactId": "a", "version": "1", "extension": "jar.sha1"}}
+  ],
+  "continuationToken": "next"
+}`
+
+const assets3Page2 = `{
+  "items": [
+    {"repository": "releases",
+     "maven2": {"groupId": "g", "artifactId": "a", "version": "1", "classifier": "sources", "extension": "jar"}}
+  ],
+  "continuationToken": null
+}`
+
+func nexus3Server() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/service/rest/v1/search/assets":
+			if r.URL.Query().Get("continuationToken") == "next" {
+				w.Write([]byte(assets3Page2))
+			} else {
+				w.Write([]byte(assets3Page1))
+			}
+		case "/service/rest/v1/repositories":
+			w.Write([]byte(`[
+			  {"name": "releases", "format": "maven2", "type": "hosted"},
+			  {"name": "central", "format": "maven2", "type": "proxy", "attributes": {"proxy": {"remoteUrl": "https://repo1.maven.org/maven2/"}}},
+			  {"name": "public", "format": "maven2", "type": "group"}
+			]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestNexus3xArtifactsFollowsContinuationTokensAndSkipsChecksums(t *testing.T) {
+	server := nexus3Server()
+	defer server.Close()
+
+	artifacts, err := New3x(server.URL, credentials.None).Artifacts(search.ByCoordinates{GroupID: "g"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	actual := []string{}
+	for _, a := range artifacts {
+		actual = append(actual, a.String())
+	}
+
+	expected := "g:a:jar:1@releases g:a:jar:sources:1@releases"
+	if strings.Join(actual, " ") != expected {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+}
+
+func TestNexus3xInfoOf(t *testing.T) {
+	server := nexus3Server()
+	defer server.Close()
+
+	artifact := &Artifact{GroupID: "g", ArtifactID: "a", Version: "1", Extension: "jar", RepositoryID: "releases"}
+	info, err := New3x(server.URL, credentials.None).InfoOf(artifact)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if info.Sha1 != "abc" || info.MimeType != "application/java-archive" ||
+		info.URL != "http://nexus/repository/releases/g/a/1/a-1.jar" || info.LastChanged.Year() != 2020 {
+		t.Errorf("Unexpected info: %v", info)
+	}
+}
+
+func TestNexus3xInfoOfFindsSnapshotsByTheirTimestampedVersion(t *testing.T) {
+	const version = "1.0-20200102.030405-1"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("version") != version || query.Get("maven.baseVersion") != "" {
+			w.Write([]byte(`{"items": [], "continuationToken": null}`))
+			return
+		}
+
+		w.Write([]byte(`{"items": [
+		  {"repository": "snapshots", "checksum": {"sha1": "abc"},
+		   "maven2": {"groupId": "g", "artifactId": "a", "version": "` + version + `", "extension": "jar"}}
+		], "continuationToken": null}`))
+	}))
+	defer server.Close()
+
+	artifact := &Artifact{GroupID: "g", ArtifactID: "a", Version: version, Extension: "jar", RepositoryID: "snapshots"}
+	info, err := New3x(server.URL, credentials.None).InfoOf(artifact)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if info.Sha1 != "abc" {
+		t.Errorf("Unexpected info: %v", info)
+	}
+}
+
+func TestNexus3xRepositoriesLeavesGroupsOut(t *testing.T) {
+	server := nexus3Server()
+	defer server.Close()
+
+	repos, err := New3x(server.URL, credentials.None).Repositories()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(repos) != 2 {
+		t.Fatalf("Expected 2 repositories, got %v", repos)
+	}
+
+	if repos[1].ID != "central" || repos[1].RemoteURI != "https://repo1.maven.org/maven2/" {
+		t.Errorf("Unexpected repository: %v", repos[1])
+	}
+}
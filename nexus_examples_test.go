@@ -3,6 +3,7 @@ package nexus_test
 import (
 	"sbrubbles.org/go/nexus"
 	"sbrubbles.org/go/nexus/credentials"
+	"sbrubbles.org/go/nexus/nexustest"
 	"sbrubbles.org/go/nexus/search"
 
 	"fmt"
@@ -10,7 +11,22 @@ import (
 )
 
 func Example() {
-	n := nexus.New("https://maven.java.net", credentials.None)
+	// a fake Nexus, so this example doesn't depend on a real one
+	server := nexustest.NewServer(&nexustest.Fixture{
+		Repositories: []*nexus.Repository{
+			{ID: "releases", Name: "Releases", Type: "hosted", Format: "maven2", Policy: "RELEASE"},
+			{ID: "central", Name: "Central", Type: "proxy", Format: "maven2", Policy: "RELEASE"},
+		},
+		Artifacts: []*nexustest.Artifact{
+			nexustest.NewArtifact("javax.enterprise:cdi-api:jar:1.2@releases", "..."),
+			nexustest.NewArtifact("javax.enterprise:cdi-api:jar:sources:1.2@releases", "..."),
+			nexustest.NewArtifact("javax.enterprise:cdi-api:pom:1.2@releases", "..."),
+			nexustest.NewArtifact("javax.enterprise:cdi-api:jar:sources:1.2@central", "..."),
+		},
+	})
+	defer server.Close()
+
+	n := nexus.New(server.URL, credentials.None)
 
 	// obtaining all repositories in Nexus
 	repositories, err := n.Repositories()
@@ -42,6 +58,9 @@ func Example() {
 			fmt.Println(a)
 		}
 	}
+
+	// Output:
+	// javax.enterprise:cdi-api:jar:sources:1.2@releases
 }
 
 func ExampleNexus2x_Artifacts() {
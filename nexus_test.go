@@ -1,8 +1,21 @@
 package nexus
 
 import (
+	"context"
 	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"sbrubbles.org/go/nexus/credentials"
+	"sbrubbles.org/go/nexus/search"
 )
 
 func TestNexus2xImplementsClient(t *testing.T) {
@@ -54,6 +67,33 @@ func TestHasWorks(t *testing.T) {
 	}
 }
 
+func TestPOMsAreFilteredOutWhenAskingForSomethingElse(t *testing.T) {
+	gav := func() []*Artifact { // consecutive POMs, which used to slip by
+		return []*Artifact{
+			{GroupID: "g", ArtifactID: "a", Version: "1", Extension: "pom", RepositoryID: "r"},
+			{GroupID: "g", ArtifactID: "a", Version: "1", Extension: "pom", RepositoryID: "s"},
+			{GroupID: "g", ArtifactID: "a", Version: "1", Extension: "jar", RepositoryID: "r"},
+			{GroupID: "g", ArtifactID: "a", Version: "1", Extension: "pom", RepositoryID: "t"},
+		}
+	}
+
+	for _, test := range []struct {
+		filter   map[string]string
+		expected int
+	}{
+		{map[string]string{"g": "g"}, 4},
+		{map[string]string{"g": "g", "p": "pom"}, 4},
+		{map[string]string{"g": "g", "p": ""}, 4},
+		{map[string]string{"g": "g", "p": "jar"}, 1},
+		{map[string]string{"g": "g", "c": "sources"}, 1},
+	} {
+		kept := keep(gav(), pomsFilterFor(test.filter))
+		if len(kept) != test.expected {
+			t.Errorf("%v: expected %v artifacts, got %v", test.filter, test.expected, kept)
+		}
+	}
+}
+
 func TestCantUnmarshalNilArtifactInfo(t *testing.T) {
 	var info *ArtifactInfo
 
@@ -83,3 +123,698 @@ func TestCantUnmarshalArtifactInfoWithANilArtifact(t *testing.T) {
 		t.Errorf("Expected a different error, not '%v'", err.Error())
 	}
 }
+
+func TestConcurrentArtifactSearchCancelsTheOtherQueriesOnError(t *testing.T) {
+	var started, stopped int32
+	block := make(chan struct{})
+
+	err := concurrentArtifactSearch(
+		context.Background(),
+		3,
+		false,
+		[]string{"wait1", "wait2", "fail"},
+		func(ctx context.Context, datum string, emit emitFunc) error {
+			if datum == "fail" {
+				<-block // let the others start first
+				return errors.New("boom")
+			}
+
+			if atomic.AddInt32(&started, 1) == 2 {
+				close(block)
+			}
+
+			<-ctx.Done() // only returns if the search cancels ctx
+			atomic.AddInt32(&stopped, 1)
+			return ctx.Err()
+		},
+		func([]*Artifact) error { return nil })
+
+	if err == nil || err.Error() != "boom" {
+		t.Errorf("Expected the error 'boom', got %v", err)
+	}
+
+	for deadline := time.Now().Add(time.Second); atomic.LoadInt32(&stopped) != 2; {
+		if time.Now().After(deadline) {
+			t.Fatalf("The other queries weren't cancelled!")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestArtifactsContextStopsOnCancel(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	n := New(server.URL, nil)
+	_, err := n.ArtifactsContext(ctx, search.ByKeyword("anything"))
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+// a single lucene page, holding one GAV with the given artifacts.
+func lucenePage(g, a, v, repo string, extensions ...string) string {
+	links := ""
+	for _, e := range extensions {
+		links += "<artifactLink><extension>" + e + "</extension></artifactLink>"
+	}
+
+	return "<searchNGResponse><data><artifact>" +
+		"<groupId>" + g + "</groupId><artifactId>" + a + "</artifactId><version>" + v + "</version>" +
+		"<artifactHits><artifactHit><repositoryId>" + repo + "</repositoryId>" +
+		"<artifactLinks>" + links + "</artifactLinks>" +
+		"</artifactHit></artifactHits></artifact></data></searchNGResponse>"
+}
+
+// serves the given pages in order, according to the 'from' parameter, and an
+// empty page after them.
+func lucenePages(pages ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		from, _ := strconv.Atoi(r.URL.Query().Get("from"))
+		if from < len(pages) {
+			w.Write([]byte(pages[from]))
+			return
+		}
+
+		w.Write([]byte("<searchNGResponse><data></data></searchNGResponse>"))
+	})
+}
+
+func TestWalkArtifactsHandsOverEachArtifactOnlyOnce(t *testing.T) {
+	server := httptest.NewServer(lucenePages(
+		lucenePage("g", "a", "1", "releases", "jar", "pom"),
+		lucenePage("g", "a", "1", "releases", "jar", "war"))) // jar again
+	defer server.Close()
+
+	seen := []string{}
+	err := Nexus2x{URL: server.URL, Credentials: credentials.None, HTTPClient: &http.Client{}}.
+		WalkArtifacts(search.ByKeyword("a"), func(a *Artifact) error {
+			seen = append(seen, a.Extension)
+			return nil
+		})
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if strings.Join(seen, ",") != "jar,pom,war" {
+		t.Errorf("Expected jar,pom,war, got %v", seen)
+	}
+}
+
+func TestWalkArtifactsStopsOnStopWalk(t *testing.T) {
+	server := httptest.NewServer(lucenePages(
+		lucenePage("g", "a", "1", "releases", "jar", "pom"),
+		lucenePage("g", "a", "2", "releases", "jar")))
+	defer server.Close()
+
+	count := 0
+	err := Nexus2x{URL: server.URL, Credentials: credentials.None, HTTPClient: &http.Client{}}.
+		WalkArtifacts(search.ByKeyword("a"), func(a *Artifact) error {
+			count++
+			return StopWalk
+		})
+
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	if count != 1 {
+		t.Errorf("Expected the walk to stop after the first artifact, not after %v", count)
+	}
+}
+
+func TestConcurrentArtifactSearchRunsAtMostTheGivenNumberOfWorkers(t *testing.T) {
+	var mutex sync.Mutex
+	running, max := 0, 0
+
+	err := concurrentArtifactSearch(
+		context.Background(),
+		2,
+		false,
+		[]string{"a", "b", "c", "d", "e"},
+		func(ctx context.Context, datum string, emit emitFunc) error {
+			mutex.Lock()
+			running++
+			if running > max {
+				max = running
+			}
+			mutex.Unlock()
+
+			time.Sleep(10 * time.Millisecond)
+
+			mutex.Lock()
+			running--
+			mutex.Unlock()
+
+			return emit([]*Artifact{{GroupID: datum}})
+		},
+		func([]*Artifact) error { return nil })
+
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	if max != 2 {
+		t.Errorf("Expected at most 2 queries at once, got %v", max)
+	}
+}
+
+func TestLimiterCapsTheRequestsInFlight(t *testing.T) {
+	var mutex sync.Mutex
+	running, max := 0, 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		running++
+		if running > max {
+			max = running
+		}
+		mutex.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mutex.Lock()
+		running--
+		mutex.Unlock()
+
+		if strings.HasSuffix(r.URL.Path, "/content/") {
+			w.Write([]byte("<content><data>" +
+				"<content-item><text>a</text><leaf>false</leaf></content-item>" +
+				"<content-item><text>b</text><leaf>false</leaf></content-item>" +
+				"<content-item><text>c</text><leaf>false</leaf></content-item>" +
+				"</data></content>"))
+			return
+		}
+
+		w.Write([]byte("<searchNGResponse><data></data></searchNGResponse>"))
+	}))
+	defer server.Close()
+
+	n := Nexus2x{
+		URL:            server.URL,
+		Credentials:    credentials.None,
+		HTTPClient:     &http.Client{},
+		MaxParallelism: 3,
+		Limiter:        NewLimiter(1),
+	}
+
+	if _, err := n.Artifacts(search.ByRepository("releases")); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if max != 1 {
+		t.Errorf("Expected at most 1 request in flight, got %v", max)
+	}
+}
+
+func TestGroupsUnmarshalsTheMembersInOrder(t *testing.T) {
+	var payload *groups
+	err := xml.Unmarshal([]byte(`<repo-groups><data><repo-groups-item>
+	  <id>public</id><name>Public Repositories</name><format>maven2</format>
+	  <repositories>
+	    <repo-group-member><id>releases</id></repo-group-member>
+	    <repo-group-member><id>central</id></repo-group-member>
+	  </repositories>
+	</repo-groups-item></data></repo-groups>`), &payload)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(*payload) != 1 || strings.Join((*payload)[0].MemberIDs, ",") != "releases,central" {
+		t.Errorf("Unexpected groups: %v", *payload)
+	}
+}
+
+func TestExpandGroupFlattensNestedGroups(t *testing.T) {
+	repos := []*Repository{{ID: "releases"}, {ID: "snapshots"}, {ID: "central"}}
+	groups := []*Group{
+		{ID: "public", MemberIDs: []string{"releases", "proxies", "snapshots"}},
+		{ID: "proxies", MemberIDs: []string{"central", "releases", "public"}},
+	}
+
+	actual := []string{}
+	for _, repo := range expandGroup(groups[0], repos, groups) {
+		actual = append(actual, repo.ID)
+	}
+
+	if strings.Join(actual, ",") != "releases,central,snapshots" {
+		t.Errorf("Expected releases,central,snapshots, got %v", actual)
+	}
+}
+
+func TestRepositoryInfoReadsTheSettingsAndTheRemoteStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/service/local/repositories/central":
+			w.Write([]byte(`<repository><data>
+			  <id>central</id><name>Central</name><repoType>proxy</repoType><repoPolicy>RELEASE</repoPolicy>
+			  <format>maven2</format><writePolicy>READ_ONLY</writePolicy>
+			  <browseable>true</browseable><indexable>true</indexable><exposed>true</exposed>
+			  <contentResourceURI>http://nexus/content/repositories/central</contentResourceURI>
+			  <notFoundCacheTTL>1440</notFoundCacheTTL><checksumPolicy>WARN</checksumPolicy>
+			  <autoBlockActive>true</autoBlockActive>
+			  <remoteStorage><remoteStorageUrl>https://repo1.maven.org/maven2/</remoteStorageUrl></remoteStorage>
+			</data></repository>`))
+		case "/service/local/repositories/central/status":
+			w.Write([]byte(`<repository-status><data>
+			  <remoteStatus>UNAVAILABLE</remoteStatus><proxyMode>BLOCKED_AUTO</proxyMode>
+			</data></repository-status>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	info, err := New(server.URL, nil).(*Nexus2x).RepositoryInfo("central")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := RepositoryInfo{
+		Repository: &Repository{
+			ID: "central", Name: "Central", Type: "proxy", Format: "maven2", Policy: "RELEASE",
+			RemoteURI: "https://repo1.maven.org/maven2/",
+		},
+		WritePolicy:        "READ_ONLY",
+		Browseable:         true,
+		Indexable:          true,
+		Exposed:            true,
+		ContentResourceURI: "http://nexus/content/repositories/central",
+		NotFoundCacheTTL:   24 * time.Hour,
+		ChecksumPolicy:     "WARN",
+		RemoteStatus:       "UNAVAILABLE",
+		ProxyMode:          "BLOCKED_AUTO",
+		AutoBlockActive:    true,
+	}
+
+	if *info.Repository != *expected.Repository {
+		t.Errorf("Expected %v, got %v", expected.Repository, info.Repository)
+	}
+
+	info.Repository = expected.Repository
+	if *info != expected {
+		t.Errorf("Expected %v, got %v", expected, info)
+	}
+}
+
+func TestSplitSearchesAreExactToTellAGroupFromItsPrefix(t *testing.T) {
+	var mutex sync.Mutex
+	queries := []string{}
+
+	// like Nexus, g is a prefix unless the search is exact; org has too many
+	// results as a prefix, but not on its own
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		query.Del("from")
+		query.Del("count")
+
+		mutex.Lock()
+		queries = append(queries, query.Encode())
+		mutex.Unlock()
+
+		switch {
+		case query.Get("exact") != "true":
+			w.Write([]byte("<searchNGResponse><tooManyResults>true</tooManyResults><data></data></searchNGResponse>"))
+		case query.Get("v") != "1":
+			lucenePages().ServeHTTP(w, r)
+		case query.Get("g") == "org":
+			lucenePages(lucenePage("org", "root", "1", "releases", "jar")).ServeHTTP(w, r)
+		case query.Get("g") == "org.*":
+			lucenePages(lucenePage("org.acme", "app", "1", "releases", "jar")).ServeHTTP(w, r)
+		default:
+			lucenePages().ServeHTTP(w, r)
+		}
+	}))
+	defer server.Close()
+
+	n := Nexus2x{URL: server.URL, Credentials: credentials.None, HTTPClient: &http.Client{}}
+	artifacts, err := n.Artifacts(search.InRepository{RepositoryID: "releases", Criteria: search.ByCoordinates{GroupID: "org", Version: "1"}})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	if len(artifacts) != 2 {
+		t.Errorf("Expected org and org.acme, got %v", artifacts)
+	}
+
+	// the prefix is spelled out, and the version goes along as it was
+	for _, expected := range []string{
+		"exact=true&g=org&repositoryId=releases&v=1",
+		"exact=true&g=org.%2A&repositoryId=releases&v=1",
+	} {
+		found := false
+		for _, query := range queries {
+			found = found || query == expected
+		}
+
+		if !found {
+			t.Errorf("Expected the search %v, got %v", expected, queries)
+		}
+	}
+}
+
+func TestBranchesSplitByArtifactIDTellWhichFailed(t *testing.T) {
+	// org on its own has too many results, so it's split by artifact ID
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		switch {
+		case query.Get("exact") != "true" || (query.Get("g") == "org" && query.Get("a") == ""):
+			w.Write([]byte("<searchNGResponse><tooManyResults>true</tooManyResults><data></data></searchNGResponse>"))
+		case query.Get("a") == "x*":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			lucenePages().ServeHTTP(w, r)
+		}
+	}))
+	defer server.Close()
+
+	n := Nexus2x{URL: server.URL, Credentials: credentials.None, HTTPClient: &http.Client{}, BestEffort: true}
+	_, err := n.Artifacts(search.InRepository{RepositoryID: "releases", Criteria: search.ByCoordinates{GroupID: "org"}})
+
+	multi, ok := err.(*MultiError)
+	if !ok {
+		t.Fatalf("Expected a *MultiError, got %v", err)
+	}
+
+	if len(multi.Errors) != 1 || multi.Errors[0].RepositoryID != "releases" || multi.Errors[0].Prefix != "org:x*" {
+		t.Errorf("Expected releases (org:x*) to fail, got %v", multi)
+	}
+}
+
+func TestBestEffortSearchesReturnPartialResultsAndTheFailedBranches(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/service/local/repositories":
+			w.Write([]byte("<repositories><data>" +
+				"<repositories-item><id>releases</id></repositories-item>" +
+				"<repositories-item><id>broken</id></repositories-item>" +
+				"</data></repositories>"))
+		case "/service/local/repositories/releases/content/":
+			w.Write([]byte("<content><data>" +
+				"<content-item><text>com</text><leaf>false</leaf></content-item>" +
+				"<content-item><text>org</text><leaf>false</leaf></content-item>" +
+				"</data></content>"))
+		case "/service/local/lucene/search":
+			if r.URL.Query().Get("g") == "org*" {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			lucenePages(lucenePage("com.acme", "a", "1", "releases", "jar")).ServeHTTP(w, r)
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	n := Nexus2x{URL: server.URL, Credentials: credentials.None, HTTPClient: &http.Client{}, BestEffort: true}
+	artifacts, err := n.Artifacts(search.All)
+
+	if len(artifacts) != 1 || artifacts[0].GroupID != "com.acme" {
+		t.Errorf("Expected the artifacts from com*, got %v", artifacts)
+	}
+
+	multi, ok := err.(*MultiError)
+	if !ok {
+		t.Fatalf("Expected a *MultiError, got %v", err)
+	}
+
+	failed := map[string]bool{}
+	for _, e := range multi.Errors {
+		failed[e.RepositoryID+" "+e.Prefix] = true
+	}
+
+	if len(failed) != 2 || !failed["releases org*"] || !failed["broken "] {
+		t.Errorf("Unexpected failed branches: %v", multi)
+	}
+}
+
+func TestBestEffortCompositeSearchesGoOnWhenABranchFails(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		switch r.URL.Path {
+		case "/service/local/repositories/releases/content/":
+			w.Write([]byte("<content><data>" +
+				"<content-item><text>com</text><leaf>false</leaf></content-item>" +
+				"<content-item><text>org</text><leaf>false</leaf></content-item>" +
+				"</data></content>"))
+		case "/service/local/repositories/other/content/":
+			w.Write([]byte("<content><data>" +
+				"<content-item><text>net</text><leaf>false</leaf></content-item>" +
+				"</data></content>"))
+		case "/service/local/lucene/search":
+			switch query.Get("repositoryId") + " " + query.Get("g") {
+			case "releases org*":
+				w.WriteHeader(http.StatusInternalServerError)
+			case "releases com*":
+				lucenePages(lucenePage("com.acme", "a", "1", "releases", "jar")).ServeHTTP(w, r)
+			case "other net*":
+				lucenePages(lucenePage("net.acme", "b", "1", "other", "jar")).ServeHTTP(w, r)
+			default:
+				lucenePages().ServeHTTP(w, r)
+			}
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	// one branch at a time, so releases fails before other is searched
+	n := Nexus2x{URL: server.URL, Credentials: credentials.None, HTTPClient: &http.Client{}, BestEffort: true, MaxParallelism: 1}
+
+	// the healthy branches are all there
+	artifacts, err := n.Artifacts(search.Or{search.ByRepository("releases"), search.ByRepository("other")})
+	if len(artifacts) != 2 {
+		t.Errorf("Expected the artifacts from com* and net*, got %v", artifacts)
+	}
+
+	multi, ok := err.(*MultiError)
+	if !ok || len(multi.Errors) != 1 || multi.Errors[0].RepositoryID != "releases" || multi.Errors[0].Prefix != "org*" {
+		t.Errorf("Expected a *MultiError for releases (org*), got %v", err)
+	}
+
+	// but what releases has can't be left out for sure, so nothing is
+	artifacts, err = n.Artifacts(search.And{search.ByRepository("other"), search.Not{Criteria: search.ByRepository("releases")}})
+	if len(artifacts) != 0 {
+		t.Errorf("Expected no artifacts, got %v", artifacts)
+	}
+
+	if _, ok := err.(*MultiError); !ok {
+		t.Errorf("Expected a *MultiError, got %v", err)
+	}
+}
+
+func TestSearchesFailOnTheFirstFailedBranchByDefault(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/service/local/repositories/releases/content/":
+			w.Write([]byte("<content><data>" +
+				"<content-item><text>org</text><leaf>false</leaf></content-item>" +
+				"</data></content>"))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	n := Nexus2x{URL: server.URL, Credentials: credentials.None, HTTPClient: &http.Client{}}
+	artifacts, err := n.Artifacts(search.ByRepository("releases"))
+
+	if artifacts != nil {
+		t.Errorf("Expected no artifacts, got %v", artifacts)
+	}
+
+	var branch *BranchError
+	if !errors.As(err, &branch) || branch.Prefix != "org*" {
+		t.Errorf("Expected a *BranchError for org*, got %v", err)
+	}
+}
+
+func TestWalkArtifactsHandsOverArtifactsBeforeThePageEnds(t *testing.T) {
+	received := make(chan bool)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("from") != "0" {
+			w.Write([]byte("<searchNGResponse><data></data></searchNGResponse>"))
+			return
+		}
+
+		// the first GAV, and then the rest only after it was handed over
+		page := lucenePage("g", "a", "1", "releases", "jar")
+		first := page[:strings.Index(page, "</artifact>")+len("</artifact>")]
+		w.Write([]byte(first))
+		w.(http.Flusher).Flush()
+
+		select {
+		case <-received:
+		case <-time.After(5 * time.Second):
+			return // the client would wait for the whole page, so cut it short
+		}
+
+		w.Write([]byte(strings.Replace(lucenePage("g", "b", "1", "releases", "jar"), "<searchNGResponse><data>", "", 1)))
+	}))
+	defer server.Close()
+
+	seen := []string{}
+	err := Nexus2x{URL: server.URL, Credentials: credentials.None, HTTPClient: &http.Client{}}.
+		WalkArtifacts(search.ByKeyword("a"), func(a *Artifact) error {
+			if len(seen) == 0 {
+				close(received)
+			}
+
+			seen = append(seen, a.ArtifactID)
+			return nil
+		})
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if strings.Join(seen, ",") != "a,b" {
+		t.Errorf("Expected a,b, got %v", seen)
+	}
+}
+
+func TestWalkArtifactsFreesTheLimiterSlotWhenItStopsEarly(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the first GAV, and then nothing until the client gives up on the page
+		page := lucenePage("g", "a", "1", "releases", "jar")
+		w.Write([]byte(page[:strings.Index(page, "</artifact>")+len("</artifact>")]))
+		w.(http.Flusher).Flush()
+
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	n := Nexus2x{URL: server.URL, Credentials: credentials.None, HTTPClient: &http.Client{}, Limiter: NewLimiter(1)}
+	err := n.WalkArtifacts(search.ByKeyword("a"), func(a *Artifact) error {
+		return StopWalk
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	release, err := n.Limiter.acquire(ctx)
+	if err != nil {
+		t.Fatalf("Expected the page's slot to be freed, got %v", err)
+	}
+	release()
+}
+
+// counts the response bodies opened and closed.
+type closeCounter struct {
+	opened, closed int32
+}
+
+func (c *closeCounter) RoundTrip(request *http.Request) (*http.Response, error) {
+	response, err := http.DefaultTransport.RoundTrip(request)
+	if err != nil {
+		return nil, err
+	}
+
+	atomic.AddInt32(&c.opened, 1)
+	response.Body = &countingBody{ReadCloser: response.Body, closed: &c.closed}
+	return response, nil
+}
+
+type countingBody struct {
+	io.ReadCloser
+	closed *int32
+}
+
+func (body *countingBody) Close() error {
+	atomic.AddInt32(body.closed, 1)
+	return body.ReadCloser.Close()
+}
+
+func TestResponseBodiesAreClosedOnErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/lucene/search"):
+			w.Write([]byte(lucenePage("g", "a", "1", "releases", "jar", "war")))
+		case strings.HasSuffix(r.URL.Path, "/repositories"):
+			w.Write([]byte("<repositories><data><repositories-item>")) // cut short
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	counter := &closeCounter{}
+	n := Nexus2x{URL: server.URL, Credentials: credentials.None, HTTPClient: &http.Client{Transport: counter}}
+
+	boom := errors.New("boom")
+	if err := n.WalkArtifacts(search.ByKeyword("a"), func(*Artifact) error { return boom }); err != boom {
+		t.Errorf("Expected %v, got %v", boom, err)
+	}
+
+	if _, err := n.Repositories(); err == nil {
+		t.Errorf("Expected an error for a truncated response")
+	}
+
+	if _, err := n.Groups(); err == nil {
+		t.Errorf("Expected an error for a 404")
+	}
+
+	if counter.opened != 3 || counter.closed < counter.opened {
+		t.Errorf("Expected all %v bodies closed, got %v", counter.opened, counter.closed)
+	}
+}
+
+var eachInDataTests = []struct {
+	format   WireFormat
+	body     string
+	expected string
+}{
+	{XML, "<content><data><content-item><text>a</text></content-item><other/><content-item><text>b</text></content-item></data></content>", "a,b"},
+	{XML, "<content><text>no</text><data><content-item><text>a</text></content-item></data></content>", "a"},
+	{XML, "<content><data></data></content>", ""},
+	{JSON, `{"count": 2, "data": [{"text": "a"}, {"text": "b"}], "more": {"data": [{"text": "no"}]}}`, "a,b"},
+	{JSON, `{"data": null}`, ""},
+	{JSON, `{"data": []}`, ""},
+}
+
+func TestEachInDataStreamsTheItems(t *testing.T) {
+	for _, test := range eachInDataTests {
+		texts := []string{}
+		err := test.format.eachInData(strings.NewReader(test.body), "content-item", nil, func(decode decodeFunc) error {
+			var item struct {
+				Text string `xml:"text" json:"text"`
+			}
+
+			if err := decode(&item); err != nil {
+				return err
+			}
+
+			texts = append(texts, item.Text)
+			return nil
+		})
+
+		if err != nil || strings.Join(texts, ",") != test.expected {
+			t.Errorf("%v %v: expected %v, got %v (error %v)", test.format, test.body, test.expected, texts, err)
+		}
+	}
+
+	if err := JSON.eachInData(strings.NewReader(`{"data": {}}`), "", nil, func(decodeFunc) error { return nil }); err == nil {
+		t.Errorf("Expected an error for data which isn't a list")
+	}
+}
@@ -0,0 +1,252 @@
+package nexustest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"sbrubbles.org/go/nexus"
+	"sbrubbles.org/go/nexus/search"
+)
+
+// Call is a call made to a FakeClient.
+type Call struct {
+	Method string        // e.g. Artifacts; the Context variants are recorded without the suffix
+	Args   []interface{} // the arguments, apart from the context
+}
+
+// String implements the fmt.Stringer interface.
+func (call Call) String() string {
+	return fmt.Sprintf("%v%v", call.Method, call.Args)
+}
+
+// FakeClient is a nexus.Client which holds everything in memory, for unit
+// tests which don't need (or want) HTTP. It searches like a Nexus 2.x would,
+// with the same semantics as Server, so search.All and search.ByRepository
+// list everything, and a search without any actual parameter (e.g.
+// search.ByKeyword("")) fails. Composite criteria (e.g. search.Or) find the
+// same artifacts a nexus.Client's would.
+//
+// The SHA1 searched by search.ByChecksum comes from the artifact's
+// ArtifactInfo, and the classes searched by search.ByClassname from Classes.
+// Every call is recorded, and Fail can make any of them fail.
+//
+// A FakeClient is safe for concurrent use, but its fields should be set
+// before the calls start.
+type FakeClient struct {
+	Known   []*nexus.Artifact     // what Artifacts searches
+	Infos   []*nexus.ArtifactInfo // what InfoOf returns; their artifacts are searched too
+	Repos   []*nexus.Repository   // what Repositories returns
+	Classes map[string][]string   // the classes in each artifact, by its String()
+	Fail    func(call Call) error // if not nil, and returns an error, the call fails with it
+
+	mutex sync.Mutex // guards calls
+	calls []Call
+}
+
+// NewFakeClient creates a FakeClient with the given contents.
+func NewFakeClient(artifacts []*nexus.Artifact, infos []*nexus.ArtifactInfo, repos []*nexus.Repository) *FakeClient {
+	return &FakeClient{Known: artifacts, Infos: infos, Repos: repos}
+}
+
+// Calls returns the calls made so far, in order.
+func (c *FakeClient) Calls() []Call {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return append([]Call{}, c.calls...)
+}
+
+// Reset forgets the calls made so far.
+func (c *FakeClient) Reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.calls = nil
+}
+
+// records the call, and tells if it should fail.
+func (c *FakeClient) record(ctx context.Context, method string, args ...interface{}) error {
+	call := Call{Method: method, Args: args}
+
+	c.mutex.Lock()
+	c.calls = append(c.calls, call)
+	c.mutex.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if c.Fail != nil {
+		return c.Fail(call)
+	}
+
+	return nil
+}
+
+// FailOn returns a function for FakeClient.Fail, which fails all calls to the
+// given method (e.g. Artifacts) with err.
+func FailOn(method string, err error) func(call Call) error {
+	return func(call Call) error {
+		if call.Method == method {
+			return err
+		}
+
+		return nil
+	}
+}
+
+// Artifacts implements the nexus.Client interface.
+func (c *FakeClient) Artifacts(criteria search.Criteria) ([]*nexus.Artifact, error) {
+	return c.ArtifactsContext(context.Background(), criteria)
+}
+
+// ArtifactsContext implements the nexus.Client interface.
+func (c *FakeClient) ArtifactsContext(ctx context.Context, criteria search.Criteria) ([]*nexus.Artifact, error) {
+	if err := c.record(ctx, "Artifacts", criteria); err != nil {
+		return nil, err
+	}
+
+	if byRange, ok := criteria.(search.ByVersionRange); ok {
+		if _, err := byRange.Filtered(); err != nil {
+			return nil, err
+		}
+	}
+
+	params := search.OrZero(criteria).Parameters()
+	if len(params) > 0 && !searchable(params) {
+		return nil, nexus.Error{
+			StatusCode: http.StatusBadRequest,
+			Status:     "400 Bad Request",
+			Message:    "Search query not found in request",
+		}
+	}
+
+	result := []*nexus.Artifact{}
+	seen := map[string]bool{}
+	for _, artifact := range c.all() {
+		if seen[artifact.String()] {
+			continue
+		}
+
+		if c.satisfies(artifact, search.OrZero(criteria)) {
+			seen[artifact.String()] = true
+			result = append(result, artifact)
+		}
+	}
+
+	return result, nil
+}
+
+// the known artifacts, plus the ones in the infos.
+func (c *FakeClient) all() []*nexus.Artifact {
+	all := append([]*nexus.Artifact{}, c.Known...)
+	for _, info := range c.Infos {
+		all = append(all, info.Artifact)
+	}
+
+	return all
+}
+
+// if the given artifact satisfies criteria. The composite ones which Nexus
+// can't run in a single search are evaluated like nexus.Client does, only
+// artifact by artifact.
+func (c *FakeClient) satisfies(artifact *nexus.Artifact, criteria search.Criteria) bool {
+	if params := criteria.Parameters(); params != nil {
+		return matches(artifact, c.sha1Of(artifact), c.Classes[artifact.String()], params)
+	}
+
+	switch criteria := criteria.(type) {
+	case search.And:
+		for _, each := range criteria {
+			if !c.satisfies(artifact, search.OrZero(each)) {
+				return false
+			}
+		}
+
+		return true
+	case search.Or:
+		for _, each := range criteria {
+			if c.satisfies(artifact, search.OrZero(each)) {
+				return true
+			}
+		}
+
+		return false
+	case search.Not:
+		return !c.satisfies(artifact, search.OrZero(criteria.Criteria))
+	case search.InRepository:
+		return artifact.RepositoryID == criteria.RepositoryID && c.satisfies(artifact, search.OrZero(criteria.Criteria))
+	case search.Filtered:
+		return c.satisfies(artifact, search.OrZero(criteria.Criteria)) &&
+			(criteria.Predicate == nil || criteria.Predicate.Matches(artifact))
+	case search.ByVersionRange:
+		filtered, err := criteria.Filtered()
+		return err == nil && c.satisfies(artifact, filtered)
+	}
+
+	return true
+}
+
+// the info about the given artifact, or nil if there's none.
+func (c *FakeClient) infoOf(artifact *nexus.Artifact) *nexus.ArtifactInfo {
+	for _, info := range c.Infos {
+		if *info.Artifact == *artifact {
+			return info
+		}
+	}
+
+	return nil
+}
+
+func (c *FakeClient) sha1Of(artifact *nexus.Artifact) string {
+	if info := c.infoOf(artifact); info != nil {
+		return info.Sha1
+	}
+
+	return ""
+}
+
+// Repositories implements the nexus.Client interface.
+func (c *FakeClient) Repositories() ([]*nexus.Repository, error) {
+	return c.RepositoriesContext(context.Background())
+}
+
+// RepositoriesContext implements the nexus.Client interface.
+func (c *FakeClient) RepositoriesContext(ctx context.Context) ([]*nexus.Repository, error) {
+	if err := c.record(ctx, "Repositories"); err != nil {
+		return nil, err
+	}
+
+	return append([]*nexus.Repository{}, c.Repos...), nil
+}
+
+// InfoOf implements the nexus.Client interface. Known artifacts without an
+// ArtifactInfo get one with just the artifact; unknown ones, a 404.
+func (c *FakeClient) InfoOf(artifact *nexus.Artifact) (*nexus.ArtifactInfo, error) {
+	return c.InfoOfContext(context.Background(), artifact)
+}
+
+// InfoOfContext implements the nexus.Client interface.
+func (c *FakeClient) InfoOfContext(ctx context.Context, artifact *nexus.Artifact) (*nexus.ArtifactInfo, error) {
+	if err := c.record(ctx, "InfoOf", artifact); err != nil {
+		return nil, err
+	}
+
+	if info := c.infoOf(artifact); info != nil {
+		return info, nil
+	}
+
+	for _, known := range c.Known {
+		if *known == *artifact {
+			return &nexus.ArtifactInfo{Artifact: known}, nil
+		}
+	}
+
+	return nil, nexus.Error{
+		StatusCode: http.StatusNotFound,
+		Status:     "404 Not Found",
+		Message:    fmt.Sprintf("%v not found", artifact),
+	}
+}
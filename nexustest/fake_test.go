@@ -0,0 +1,122 @@
+package nexustest_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"sbrubbles.org/go/nexus"
+	"sbrubbles.org/go/nexus/nexustest"
+	"sbrubbles.org/go/nexus/search"
+)
+
+// a FakeClient with the same contents as the given fixture.
+func fakeOf(f *nexustest.Fixture) *nexustest.FakeClient {
+	client := nexustest.NewFakeClient(nil, nil, f.Repositories)
+	client.Classes = map[string][]string{}
+
+	for _, artifact := range f.Artifacts {
+		client.Infos = append(client.Infos, &nexus.ArtifactInfo{Artifact: artifact.Artifact, Sha1: artifact.Sha1()})
+		client.Classes[artifact.String()] = artifact.Classes
+	}
+
+	return client
+}
+
+func TestFakeClientImplementsClient(t *testing.T) {
+	if _, ok := interface{}(&nexustest.FakeClient{}).(nexus.Client); !ok {
+		t.Errorf("nexustest.FakeClient does not implement nexus.Client!")
+	}
+}
+
+func TestFakeClientSearchesLikeTheServer(t *testing.T) {
+	client := fakeOf(fixture())
+
+	for _, test := range searchTests {
+		artifacts, err := client.Artifacts(test.criteria)
+		if err != nil {
+			t.Errorf("%v: unexpected error %v", test.criteria, err)
+			continue
+		}
+
+		if actual := coordinatesOf(artifacts); !equal(actual, test.expected) {
+			t.Errorf("%v: expected %v, got %v", test.criteria, test.expected, actual)
+		}
+	}
+
+	all, err := client.Artifacts(nil)
+	if err != nil || len(all) != len(fixture().Artifacts) {
+		t.Errorf("Expected everything, got %v (error %v)", coordinatesOf(all), err)
+	}
+
+	if _, err := client.Artifacts(search.ByKeyword("")); err == nil {
+		t.Errorf("Expected an error")
+	}
+}
+
+func TestFakeClientInfoOf(t *testing.T) {
+	known := &nexus.Artifact{GroupID: "g", ArtifactID: "a", Version: "1", Extension: "jar", RepositoryID: "r"}
+	described := &nexus.ArtifactInfo{
+		Artifact: &nexus.Artifact{GroupID: "g", ArtifactID: "b", Version: "1", Extension: "jar", RepositoryID: "r"},
+		Sha1:     "abc",
+	}
+
+	client := nexustest.NewFakeClient([]*nexus.Artifact{known}, []*nexus.ArtifactInfo{described}, nil)
+
+	// a copy, as a caller would have
+	info, err := client.InfoOf(&nexus.Artifact{GroupID: "g", ArtifactID: "b", Version: "1", Extension: "jar", RepositoryID: "r"})
+	if err != nil || info != described {
+		t.Errorf("Expected %v, got %v (error %v)", described, info, err)
+	}
+
+	info, err = client.InfoOf(known)
+	if err != nil || info.Artifact != known {
+		t.Errorf("Expected an info for %v, got %v (error %v)", known, info, err)
+	}
+
+	_, err = client.InfoOf(&nexus.Artifact{GroupID: "g", ArtifactID: "c", Version: "1", Extension: "jar", RepositoryID: "r"})
+	if e, ok := err.(nexus.Error); !ok || e.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected a 404, got %v", err)
+	}
+}
+
+func TestFakeClientRecordsCalls(t *testing.T) {
+	client := fakeOf(fixture())
+
+	client.Repositories()
+	client.ArtifactsContext(context.Background(), search.ByKeyword("app"))
+
+	calls := client.Calls()
+	if len(calls) != 2 || calls[0].Method != "Repositories" || calls[1].Method != "Artifacts" ||
+		calls[1].Args[0] != search.ByKeyword("app") {
+		t.Errorf("Unexpected calls %v", calls)
+	}
+
+	client.Reset()
+	if calls := client.Calls(); len(calls) != 0 {
+		t.Errorf("Expected no calls, got %v", calls)
+	}
+}
+
+func TestFakeClientFails(t *testing.T) {
+	boom := errors.New("boom")
+
+	client := fakeOf(fixture())
+	client.Fail = nexustest.FailOn("Repositories", boom)
+
+	if _, err := client.Repositories(); err != boom {
+		t.Errorf("Expected %v, got %v", boom, err)
+	}
+
+	if _, err := client.Artifacts(search.ByKeyword("app")); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := client.ArtifactsContext(ctx, nil); err != context.Canceled {
+		t.Errorf("Expected %v, got %v", context.Canceled, err)
+	}
+}
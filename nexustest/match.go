@@ -0,0 +1,107 @@
+package nexustest
+
+import (
+	"path"
+	"strings"
+
+	"sbrubbles.org/go/nexus"
+)
+
+// the search parameters Nexus 2.x's lucene search understands; the others
+// (e.g. from, count) are about paging.
+var searchParameters = []string{"g", "a", "v", "p", "c", "q", "cn", "sha1", "repositoryId"}
+
+// if params holds at least one actual search parameter. Nexus refuses
+// searches without any.
+func searchable(params map[string]string) bool {
+	for _, key := range searchParameters {
+		if params[key] != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matches tells if the given artifact, whose content has the given SHA1 and
+// classes, satisfies all of the given search parameters, following Nexus
+// 2.x's semantics, more or less:
+//   - g and a match the values starting with them (e.g. g=org.acme matches
+//     org.acme.web too), unless exact=true, and v, c and p (the extension) the
+//     whole value, all ignoring case, with an asterisk matching any sequence
+//     of characters (e.g. g=org.*.web);
+//   - q matches either the group ID or the artifact ID, as if it were
+//     surrounded by *s;
+//   - cn matches either the qualified or the simple name of one of the classes;
+//   - sha1 matches the content's checksum, ignoring case;
+//   - repositoryId is the exact repository.
+//
+// Empty values and unknown keys are ignored.
+func matches(artifact *nexus.Artifact, sha1 string, classes []string, params map[string]string) bool {
+	fields := map[string]string{
+		"g": artifact.GroupID,
+		"a": artifact.ArtifactID,
+		"v": artifact.Version,
+		"c": artifact.Classifier,
+		"p": artifact.Extension,
+	}
+
+	for key, value := range fields {
+		pattern := params[key]
+		if pattern == "" {
+			continue
+		}
+
+		if (key == "g" || key == "a") && params["exact"] != "true" && !strings.HasSuffix(pattern, "*") {
+			pattern += "*" // prefixes
+		}
+
+		if !glob(pattern, value) {
+			return false
+		}
+	}
+
+	if q := params["q"]; q != "" {
+		pattern := "*" + strings.Trim(q, "*") + "*"
+		if !glob(pattern, artifact.GroupID) && !glob(pattern, artifact.ArtifactID) {
+			return false
+		}
+	}
+
+	if cn := params["cn"]; cn != "" && !hasClass(classes, cn) {
+		return false
+	}
+
+	if checksum := params["sha1"]; checksum != "" && !strings.EqualFold(checksum, sha1) {
+		return false
+	}
+
+	if repo := params["repositoryId"]; repo != "" && repo != artifact.RepositoryID {
+		return false
+	}
+
+	return true
+}
+
+// if one of the given classes matches the given pattern.
+func hasClass(classes []string, pattern string) bool {
+	for _, class := range classes {
+		simple := class[strings.LastIndex(class, ".")+1:]
+		if glob(pattern, class) || glob(pattern, simple) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matches value against a pattern where * is the only wildcard, ignoring case.
+func glob(pattern string, value string) bool {
+	// path.Match has other special characters, which Maven coordinates may have
+	replacer := strings.NewReplacer(`\`, `\\`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+	matched, err := path.Match(
+		strings.ToLower(replacer.Replace(pattern)),
+		strings.ToLower(value))
+	return err == nil && matched
+}
@@ -0,0 +1,248 @@
+/*
+Package nexustest provides fakes of Nexus for tests, so code built on package
+nexus can be tested without a live Nexus.
+
+Server is an in-process Nexus 2.x, speaking HTTP, and serving a Fixture defined
+in Go or loaded from a directory with a Maven repository layout.
+
+FakeClient skips HTTP altogether, implementing nexus.Client in memory, with the
+same search semantics as Server. It records its calls, and can be told to
+fail.
+
+Recorder records the HTTP interactions with a real Nexus (2.x or 3.x) to a
+cassette file, and replays them later, so regressions can be tested against
+what a real server said.
+*/
+package nexustest // import "sbrubbles.org/go/nexus/nexustest"
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"sbrubbles.org/go/nexus"
+)
+
+// Artifact is an artifact held by a fake Nexus, along with its contents and
+// some metadata.
+type Artifact struct {
+	*nexus.Artifact
+
+	Content  []byte    // the file's bytes
+	Uploader string    // e.g. deployment
+	Uploaded time.Time // also used as the last change
+	Classes  []string  // e.g. com.acme.Main, for search.ByClassname
+}
+
+// NewArtifact builds an artifact from the given coordinates, in the same
+// format nexus.Artifact.String() uses (e.g. g:a:jar:sources:1.0@releases), and
+// the given content. It panics if the coordinates can't be parsed, so it's
+// meant for fixtures defined in code.
+func NewArtifact(coordinates string, content string) *Artifact {
+	artifact, err := parseCoordinates(coordinates)
+	if err != nil {
+		panic(err)
+	}
+
+	return &Artifact{Artifact: artifact, Content: []byte(content)}
+}
+
+func parseCoordinates(coordinates string) (*nexus.Artifact, error) {
+	at := strings.LastIndex(coordinates, "@")
+	if at < 0 {
+		return nil, fmt.Errorf("No repository in %q", coordinates)
+	}
+
+	parts := strings.Split(coordinates[:at], ":")
+	repo := coordinates[at+1:]
+
+	switch len(parts) {
+	case 4: // g:a:e:v
+		return &nexus.Artifact{GroupID: parts[0], ArtifactID: parts[1], Extension: parts[2],
+			Version: parts[3], RepositoryID: repo}, nil
+	case 5: // g:a:e:c:v
+		return &nexus.Artifact{GroupID: parts[0], ArtifactID: parts[1], Extension: parts[2],
+			Classifier: parts[3], Version: parts[4], RepositoryID: repo}, nil
+	}
+
+	return nil, fmt.Errorf("Can't parse the coordinates %q", coordinates)
+}
+
+// Sha1 returns the SHA1 of the artifact's content, in hex.
+func (a *Artifact) Sha1() string {
+	sum := sha1.Sum(a.Content)
+	return hex.EncodeToString(sum[:])
+}
+
+// Path returns the artifact's path in its repository, in Maven's layout (e.g.
+// /com/acme/app/1.0/app-1.0-sources.jar).
+func (a *Artifact) Path() string {
+	file := a.ArtifactID + "-" + a.Version
+	if a.Classifier != "" {
+		file += "-" + a.Classifier
+	}
+
+	return "/" + strings.Replace(a.GroupID, ".", "/", -1) + "/" + a.ArtifactID + "/" +
+		a.Version + "/" + file + "." + a.Extension
+}
+
+// MimeType returns the artifact's MIME type, guessed from its extension.
+func (a *Artifact) MimeType() string {
+	if t := mime.TypeByExtension("." + a.Extension); t != "" {
+		return t
+	}
+
+	switch a.Extension {
+	case "pom":
+		return "application/xml"
+	case "jar", "war", "ear":
+		return "application/java-archive"
+	}
+
+	return "application/octet-stream"
+}
+
+// Fixture is what a fake Nexus holds.
+type Fixture struct {
+	Repositories []*nexus.Repository
+	Groups       []*nexus.Group
+	Artifacts    []*Artifact
+}
+
+// sorts the artifacts, so the fakes always answer in the same order.
+func (f *Fixture) sort() {
+	sort.SliceStable(f.Artifacts, func(i, j int) bool {
+		a, b := f.Artifacts[i], f.Artifacts[j]
+		return a.GroupID+":"+a.ArtifactID+":"+a.Version+":"+a.RepositoryID+":"+a.Classifier+":"+a.Extension <
+			b.GroupID+":"+b.ArtifactID+":"+b.Version+":"+b.RepositoryID+":"+b.Classifier+":"+b.Extension
+	})
+}
+
+// the repository with the given ID, or nil if there's none.
+func (f *Fixture) repository(id string) *nexus.Repository {
+	for _, repo := range f.Repositories {
+		if repo.ID == id {
+			return repo
+		}
+	}
+
+	return nil
+}
+
+// LoadFixture reads a fixture from dir, where each subdirectory is a hosted
+// repository (named after the subdirectory) in Maven's layout. A repository
+// whose versions are all SNAPSHOTs gets the SNAPSHOT policy; the others get
+// RELEASE. Files which don't follow Maven's naming (e.g. maven-metadata.xml,
+// checksums) are ignored.
+func LoadFixture(dir string) (*Fixture, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	fixture := &Fixture{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		repo := &nexus.Repository{ID: entry.Name(), Name: entry.Name(), Type: "hosted", Format: "maven2"}
+		root := filepath.Join(dir, entry.Name())
+
+		snapshots, releases := 0, 0
+		err := filepath.Walk(root, func(file string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+
+			rel, err := filepath.Rel(root, file)
+			if err != nil {
+				return err
+			}
+
+			artifact := artifactAt(filepath.ToSlash(rel), repo.ID)
+			if artifact == nil {
+				return nil
+			}
+
+			content, err := os.ReadFile(file)
+			if err != nil {
+				return err
+			}
+
+			if strings.HasSuffix(artifact.Version, "-SNAPSHOT") {
+				snapshots++
+			} else {
+				releases++
+			}
+
+			fixture.Artifacts = append(fixture.Artifacts,
+				&Artifact{Artifact: artifact, Content: content, Uploaded: info.ModTime()})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		repo.Policy = "RELEASE"
+		if snapshots > 0 && releases == 0 {
+			repo.Policy = "SNAPSHOT"
+		}
+
+		fixture.Repositories = append(fixture.Repositories, repo)
+	}
+
+	fixture.sort()
+	return fixture, nil
+}
+
+// the artifact at the given path (e.g. com/acme/app/1.0/app-1.0.jar), or nil
+// if the path doesn't follow Maven's layout.
+func artifactAt(p string, repositoryID string) *nexus.Artifact {
+	dir, file := path.Split(p)
+	parts := strings.Split(strings.Trim(dir, "/"), "/")
+	if len(parts) < 3 {
+		return nil
+	}
+
+	version := parts[len(parts)-1]
+	artifactID := parts[len(parts)-2]
+	groupID := strings.Join(parts[:len(parts)-2], ".")
+
+	prefix := artifactID + "-" + version
+	if !strings.HasPrefix(file, prefix) {
+		return nil
+	}
+
+	rest := file[len(prefix):] // e.g. .jar, -sources.jar
+	classifier := ""
+	if strings.HasPrefix(rest, "-") {
+		dot := strings.Index(rest, ".")
+		if dot < 0 {
+			return nil
+		}
+
+		classifier, rest = rest[1:dot], rest[dot:]
+	}
+
+	extension := strings.TrimPrefix(rest, ".")
+	if !strings.HasPrefix(rest, ".") || extension == "" {
+		return nil
+	}
+
+	for _, suffix := range []string{"md5", "sha1", "sha256", "sha512", "asc"} {
+		if extension == suffix || strings.HasSuffix(extension, "."+suffix) {
+			return nil
+		}
+	}
+
+	return &nexus.Artifact{GroupID: groupID, ArtifactID: artifactID, Version: version,
+		Classifier: classifier, Extension: extension, RepositoryID: repositoryID}
+}
@@ -0,0 +1,246 @@
+package nexustest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+
+	"sbrubbles.org/go/nexus"
+)
+
+// Mode says what a Recorder does with the requests it gets.
+type Mode int
+
+const (
+	// Replay answers the requests from the cassette, without going to the
+	// network. A request not in the cassette fails with a *NoInteractionError.
+	Replay Mode = iota
+
+	// Record sends the requests to the server, and records the interactions in
+	// the cassette.
+	Record
+)
+
+// Interaction is a request and its response, as recorded in a cassette.
+// Requests are identified by their method, path and query; the query is
+// normalized (its parameters sorted), since nexus.Nexus2x sends them in no
+// particular order.
+type Interaction struct {
+	Method        string      `json:"method"`               // e.g. GET
+	Path          string      `json:"path"`                 // e.g. /service/local/lucene/search
+	Query         string      `json:"query,omitempty"`      // e.g. a=app&g=com.acme
+	RequestHeader http.Header `json:"requestHeader"`        // with the credentials redacted (see nexus.RedactHeader)
+	StatusCode    int         `json:"statusCode"`           // e.g. 200
+	Header        http.Header `json:"header"`               // the response's, with the cookies redacted
+	Body          string      `json:"body,omitempty"`       // the response's, if it's text
+	BinaryBody    []byte      `json:"binaryBody,omitempty"` // the response's, if it isn't
+}
+
+// Cassette holds the interactions a Recorder records or replays.
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// LoadCassette reads a cassette from the given file.
+func LoadCassette(path string) (*Cassette, error) {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cassette Cassette
+	if err := json.Unmarshal(body, &cassette); err != nil {
+		return nil, err
+	}
+
+	return &cassette, nil
+}
+
+// Save writes the cassette to the given file, as indented JSON, so it's
+// somewhat readable and diffable.
+func (c *Cassette) Save(path string) error {
+	body, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(body, '\n'), 0644)
+}
+
+// NoInteractionError is returned by a Recorder replaying a cassette which
+// doesn't have the request.
+type NoInteractionError struct {
+	Method string // e.g. GET
+	Path   string // e.g. /service/local/lucene/search
+	Query  string // e.g. a=app&g=com.acme, normalized
+}
+
+// Error implements the error interface.
+func (err NoInteractionError) Error() string {
+	return fmt.Sprintf("No recorded interaction for %v %v?%v", err.Method, err.Path, err.Query)
+}
+
+// Recorder is an http.RoundTripper which records interactions with a real
+// Nexus to a cassette, and replays them later, for tests which don't depend
+// on the network. Use it as the Transport of a client's HTTPClient.
+//
+// When recording, the cassette is saved to Path after each interaction, so
+// it's there even if the test stops midway. When replaying, each request gets
+// the first recorded interaction matching it which wasn't used yet, so the
+// same request may get different responses (e.g. a retry after an error).
+// When they're all used, the last one is repeated.
+type Recorder struct {
+	Base     http.RoundTripper // where to send the requests when recording; http.DefaultTransport if nil
+	Mode     Mode              // Replay or Record
+	Cassette *Cassette         // the interactions
+	Path     string            // where to save the cassette when recording; not saved if empty
+
+	mutex sync.Mutex // guards Cassette and used
+	used  map[*Interaction]bool
+}
+
+// NewRecorder creates a Recorder in the given mode. When replaying, the
+// cassette is read from path; when recording, the cassette starts empty, and
+// is saved to path as the interactions are recorded.
+func NewRecorder(path string, mode Mode) (*Recorder, error) {
+	if mode == Record {
+		recorder := &Recorder{Mode: mode, Cassette: &Cassette{}, Path: path}
+		if err := recorder.Save(path); err != nil { // fails early if path can't be written
+			return nil, err
+		}
+
+		return recorder, nil
+	}
+
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Recorder{Mode: mode, Cassette: cassette, Path: path}, nil
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (r *Recorder) RoundTrip(request *http.Request) (*http.Response, error) {
+	if r.Mode == Record {
+		return r.record(request)
+	}
+
+	return r.replay(request)
+}
+
+func normalizedQuery(request *http.Request) string {
+	return request.URL.Query().Encode()
+}
+
+func (r *Recorder) replay(request *http.Request) (*http.Response, error) {
+	if request.Body != nil {
+		request.Body.Close()
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	query := normalizedQuery(request)
+
+	var found *Interaction
+	for _, interaction := range r.Cassette.Interactions {
+		if interaction.Method != request.Method || interaction.Path != request.URL.Path || interaction.Query != query {
+			continue
+		}
+
+		found = interaction
+		if !r.used[interaction] {
+			break
+		}
+	}
+
+	if found == nil {
+		return nil, &NoInteractionError{Method: request.Method, Path: request.URL.Path, Query: query}
+	}
+
+	if r.used == nil {
+		r.used = map[*Interaction]bool{}
+	}
+	r.used[found] = true
+
+	body := []byte(found.Body)
+	if found.BinaryBody != nil {
+		body = found.BinaryBody
+	}
+
+	return &http.Response{
+		Status:        strconv.Itoa(found.StatusCode) + " " + http.StatusText(found.StatusCode),
+		StatusCode:    found.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        found.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       request,
+	}, nil
+}
+
+func (r *Recorder) record(request *http.Request) (*http.Response, error) {
+	base := r.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	response, err := base.RoundTrip(request)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	interaction := &Interaction{
+		Method:        request.Method,
+		Path:          request.URL.Path,
+		Query:         normalizedQuery(request),
+		RequestHeader: nexus.RedactHeader(request.Header),
+		StatusCode:    response.StatusCode,
+		Header:        nexus.RedactHeader(response.Header),
+	}
+
+	if utf8.Valid(body) {
+		interaction.Body = string(body)
+	} else {
+		interaction.BinaryBody = body
+	}
+
+	r.mutex.Lock()
+	r.Cassette.Interactions = append(r.Cassette.Interactions, interaction)
+	if r.Path != "" {
+		err = r.Cassette.Save(r.Path)
+	}
+	r.mutex.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	response.Body = io.NopCloser(bytes.NewReader(body))
+	return response, nil
+}
+
+// Save writes the cassette to the given file (e.g. to keep a copy, or when
+// Path is empty). It's safe to call while requests are in flight, although
+// they won't be in the file.
+func (r *Recorder) Save(path string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.Cassette.Save(path)
+}
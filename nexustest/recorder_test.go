@@ -0,0 +1,98 @@
+package nexustest_test
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sbrubbles.org/go/nexus"
+	"sbrubbles.org/go/nexus/credentials"
+	"sbrubbles.org/go/nexus/nexustest"
+	"sbrubbles.org/go/nexus/search"
+)
+
+func TestRecorderRecordsAndReplays(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	criteria := search.ByCoordinates{GroupID: "com.acme", Classifier: "sources"}
+
+	// recording
+	server := nexustest.NewServer(fixture())
+
+	recorder, err := nexustest.NewRecorder(path, nexustest.Record)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	n := &nexus.Nexus2x{
+		URL:         server.URL,
+		Credentials: credentials.BasicAuth("user", "secret"),
+		HTTPClient:  &http.Client{Transport: recorder},
+	}
+
+	recorded, err := n.Artifacts(criteria)
+	server.Close()
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	body, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	// the Basic header holds the credentials in base64
+	if strings.Contains(string(body), "Basic ") || !strings.Contains(string(body), nexus.Redacted) {
+		t.Errorf("Expected the credentials to be redacted in %s", body)
+	}
+
+	// replaying, with the server gone
+	recorder, err = nexustest.NewRecorder(path, nexustest.Replay)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	n.HTTPClient = &http.Client{Transport: recorder}
+
+	replayed, err := n.Artifacts(criteria)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	if !equal(coordinatesOf(recorded), coordinatesOf(replayed)) {
+		t.Errorf("Expected %v, got %v", coordinatesOf(recorded), coordinatesOf(replayed))
+	}
+
+	// http.Client wraps the transport's errors
+	_, err = n.Artifacts(search.ByKeyword("other"))
+	var noInteraction *nexustest.NoInteractionError
+	if !errors.As(err, &noInteraction) {
+		t.Errorf("Expected a *NoInteractionError, got %v", err)
+	}
+}
+
+func TestRecorderIgnoresTheQueryOrder(t *testing.T) {
+	recorder := &nexustest.Recorder{Mode: nexustest.Replay, Cassette: &nexustest.Cassette{
+		Interactions: []*nexustest.Interaction{
+			{Method: "GET", Path: "/search", Query: "a=1&b=2", StatusCode: 500},
+			{Method: "GET", Path: "/search", Query: "a=1&b=2", StatusCode: 200, Body: "ok"},
+		},
+	}}
+
+	client := &http.Client{Transport: recorder}
+
+	// the first match, then the second, and then the last one again
+	for _, expected := range []int{500, 200, 200} {
+		resp, err := client.Get("http://nexus.invalid/search?b=2&a=1")
+		if err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != expected {
+			t.Errorf("Expected %v, got %v", expected, resp.Status)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package nexustest
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strconv"
+)
+
+// the parts of the lucene search's response.
+type (
+	artifactLink struct {
+		Extension  string `xml:"extension" json:"extension"`
+		Classifier string `xml:"classifier,omitempty" json:"classifier,omitempty"`
+	}
+
+	artifactHit struct {
+		RepositoryID string         `xml:"repositoryId" json:"repositoryId"`
+		Links        []artifactLink `xml:"artifactLinks>artifactLink" json:"artifactLinks"`
+	}
+
+	artifactRow struct {
+		GroupID    string        `xml:"groupId" json:"groupId"`
+		ArtifactID string        `xml:"artifactId" json:"artifactId"`
+		Version    string        `xml:"version" json:"version"`
+		Hits       []artifactHit `xml:"artifactHits>artifactHit" json:"artifactHits"`
+	}
+)
+
+// the lucene search. Its parameters are described in matches, and the paging
+// in NewServer.
+func (s *Server) search(w http.ResponseWriter, r *http.Request) {
+	params := map[string]string{}
+	for key, values := range r.URL.Query() {
+		params[key] = values[0]
+	}
+
+	if !searchable(params) {
+		fail(w, r, http.StatusBadRequest, "Search query not found in request")
+		return
+	}
+
+	// like Nexus, the page size is sent back as -1 if the search didn't ask
+	// for one
+	from, _ := strconv.Atoi(params["from"])
+	count, echoed := s.pageSize(), -1
+	if asked, err := strconv.Atoi(params["count"]); err == nil && asked > 0 {
+		if asked < count {
+			count = asked
+		}
+
+		echoed = count
+	}
+
+	matched := []*Artifact{}
+	for _, artifact := range s.fixture.Artifacts {
+		if matches(artifact.Artifact, artifact.Sha1(), artifact.Classes, params) {
+			matched = append(matched, artifact)
+		}
+	}
+
+	// the paging units: whole GAVs, or (if quirky) the artifacts which count
+	units := [][]*Artifact{}
+	if s.Quirky {
+		// the POMs which don't count go along with the first artifact of their
+		// GAV which does
+		first := map[string]int{}
+		for _, artifact := range matched {
+			if artifact.Extension != "pom" || !hasNonPOMs(matched, artifact) {
+				if _, ok := first[hitOf(artifact)]; !ok {
+					first[hitOf(artifact)] = len(units)
+				}
+
+				units = append(units, []*Artifact{artifact})
+			}
+		}
+
+		for _, artifact := range matched {
+			if artifact.Extension == "pom" && hasNonPOMs(matched, artifact) {
+				i := first[hitOf(artifact)]
+				units[i] = append(units[i], artifact)
+			}
+		}
+	} else {
+		for _, artifact := range matched {
+			last := len(units) - 1
+			if last >= 0 && sameGAV(units[last][0], artifact) {
+				units[last] = append(units[last], artifact)
+			} else {
+				units = append(units, []*Artifact{artifact})
+			}
+		}
+	}
+
+	tooMany := s.MaxResults > 0 && len(units) > s.MaxResults
+
+	page := []*Artifact{}
+	if !tooMany && from >= 0 && from < len(units) {
+		to := from + count
+		if to > len(units) {
+			to = len(units)
+		}
+
+		for _, unit := range units[from:to] {
+			page = append(page, unit...)
+		}
+	}
+
+	// Nexus includes the POMs even when the search says otherwise
+	rows := s.rowsOf(page, params["p"] != "" || params["c"] != "")
+
+	reply(w, r, struct {
+		XMLName        xml.Name      `xml:"searchNGResponse" json:"-"`
+		TotalCount     int           `xml:"totalCount" json:"totalCount"`
+		From           int           `xml:"from" json:"from"`
+		Count          int           `xml:"count" json:"count"`
+		TooManyResults bool          `xml:"tooManyResults" json:"tooManyResults"`
+		Collapsed      bool          `xml:"collapsed" json:"collapsed"`
+		Data           []artifactRow `xml:"data>artifact" json:"data"`
+	}{
+		TotalCount:     len(units),
+		From:           from,
+		Count:          echoed,
+		TooManyResults: tooMany,
+		Data:           rows,
+	})
+}
+
+// groups the given artifacts by GAV, and then by repository. If withPOMs is
+// true, every hit also links to its GAV's POM, even if it didn't match.
+func (s *Server) rowsOf(artifacts []*Artifact, withPOMs bool) []artifactRow {
+	rows := []artifactRow{}
+
+	for _, artifact := range artifacts {
+		if len(rows) == 0 || !rows[len(rows)-1].holds(artifact) {
+			rows = append(rows, artifactRow{
+				GroupID:    artifact.GroupID,
+				ArtifactID: artifact.ArtifactID,
+				Version:    artifact.Version,
+			})
+		}
+
+		row := &rows[len(rows)-1]
+		if len(row.Hits) == 0 || row.Hits[len(row.Hits)-1].RepositoryID != artifact.RepositoryID {
+			row.Hits = append(row.Hits, artifactHit{RepositoryID: artifact.RepositoryID})
+		}
+
+		hit := &row.Hits[len(row.Hits)-1]
+		hit.Links = append(hit.Links, artifactLink{artifact.Extension, artifact.Classifier})
+	}
+
+	for i := 0; withPOMs && i < len(rows); i++ {
+		for j := range rows[i].Hits {
+			hit := &rows[i].Hits[j]
+			if !hasLink(hit.Links, "pom", "") && s.pomOf(&rows[i], hit.RepositoryID) {
+				hit.Links = append(hit.Links, artifactLink{Extension: "pom"})
+			}
+		}
+	}
+
+	return rows
+}
+
+// if the artifact has this row's GAV.
+func (row artifactRow) holds(artifact *Artifact) bool {
+	return row.GroupID == artifact.GroupID && row.ArtifactID == artifact.ArtifactID && row.Version == artifact.Version
+}
+
+// if there's a POM for the given GAV in the given repository.
+func (s *Server) pomOf(row *artifactRow, repositoryID string) bool {
+	for _, artifact := range s.fixture.Artifacts {
+		if row.holds(artifact) && artifact.RepositoryID == repositoryID && artifact.Extension == "pom" && artifact.Classifier == "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hasLink(links []artifactLink, extension string, classifier string) bool {
+	for _, link := range links {
+		if link.Extension == extension && link.Classifier == classifier {
+			return true
+		}
+	}
+
+	return false
+}
+
+// if something other than a POM in pom's GAV and repository was matched.
+func hasNonPOMs(matched []*Artifact, pom *Artifact) bool {
+	for _, artifact := range matched {
+		if sameGAV(artifact, pom) && artifact.RepositoryID == pom.RepositoryID && artifact.Extension != "pom" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// identifies the artifact's GAV and repository, i.e. its hit.
+func hitOf(artifact *Artifact) string {
+	return artifact.GroupID + ":" + artifact.ArtifactID + ":" + artifact.Version + "@" + artifact.RepositoryID
+}
+
+func sameGAV(a, b *Artifact) bool {
+	return a.GroupID == b.GroupID && a.ArtifactID == b.ArtifactID && a.Version == b.Version
+}
@@ -0,0 +1,565 @@
+package nexustest
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"sbrubbles.org/go/nexus"
+)
+
+// DefaultPageSize is how many results a search page holds, unless the Server
+// says otherwise, or the search asks for fewer (with count). Like Nexus, the
+// Server only tells the page size if the search asked for one; otherwise, it
+// sends -1.
+const DefaultPageSize = 200
+
+// DefaultVersion is the Nexus version a Server reports, unless told otherwise.
+const DefaultVersion = "2.14.5-02"
+
+// Server is a fake Nexus 2.x, serving a Fixture over HTTP. It implements the
+// parts of the API nexus.Nexus2x uses: the status, repositories, groups, the
+// repositories' content (listings, files, describe=info and deletions), the
+// artifact resolution and the lucene search, with its paging. It answers in
+// XML, or in JSON if the request accepts it, like Nexus does. Deployments
+// aren't supported.
+//
+// Like httptest.Server, the configuration fields must be set before the server
+// starts; use NewUnstartedServer for that.
+type Server struct {
+	*httptest.Server
+
+	PageSize   int    // the maximum results in a search page; DefaultPageSize if < 1
+	MaxResults int    // searches with more results are refused with tooManyResults; no limit if < 1
+	Quirky     bool   // if the search pages like a real Nexus; see NewServer
+	Version    string // e.g. 2.14.5-02; DefaultVersion if empty
+
+	mutex   sync.RWMutex // guards fixture, which deletions change
+	fixture *Fixture
+}
+
+// NewServer starts and returns a new Server, serving the given fixture. Call
+// Close when done.
+//
+// The search pages by GAV, as the lucene search's response suggests, unless the
+// server is Quirky. In that case it pages like a real Nexus, counting the
+// artifacts which aren't POMs (a POM counts only if nothing else in its GAV
+// matched), but still grouping the results by GAV. So a GAV may be split across
+// pages, and whoever pages by the number of GAVs returned will see some of them
+// again in the next page (see nexus.Nexus2x.Artifacts).
+//
+// The server works on a copy of the fixture's lists, but the artifacts and
+// repositories themselves are shared, and shouldn't be changed while it runs.
+func NewServer(fixture *Fixture) *Server {
+	server := NewUnstartedServer(fixture)
+	server.Start()
+
+	return server
+}
+
+// NewUnstartedServer returns a new Server, serving the given fixture, but
+// doesn't start it. After changing its configuration, the caller should call
+// Start, and Close when done.
+func NewUnstartedServer(fixture *Fixture) *Server {
+	if fixture == nil {
+		fixture = &Fixture{}
+	}
+
+	// a copy, so deletions don't show in the caller's fixture
+	own := &Fixture{
+		Repositories: append([]*nexus.Repository{}, fixture.Repositories...),
+		Groups:       append([]*nexus.Group{}, fixture.Groups...),
+		Artifacts:    append([]*Artifact{}, fixture.Artifacts...),
+	}
+	own.sort()
+
+	server := &Server{fixture: own}
+	server.Server = httptest.NewUnstartedServer(http.HandlerFunc(server.serve))
+
+	return server
+}
+
+// Fixture returns a copy of what the server currently holds, which reflects
+// the deletions it served.
+func (s *Server) Fixture() *Fixture {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return &Fixture{
+		Repositories: append([]*nexus.Repository{}, s.fixture.Repositories...),
+		Groups:       append([]*nexus.Group{}, s.fixture.Groups...),
+		Artifacts:    append([]*Artifact{}, s.fixture.Artifacts...),
+	}
+}
+
+func (s *Server) pageSize() int {
+	if s.PageSize < 1 {
+		return DefaultPageSize
+	}
+
+	return s.PageSize
+}
+
+// routes the request to the proper handler.
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/nexus") // mimics the usual context path
+	if !strings.HasPrefix(path, "/service/local/") {
+		fail(w, r, http.StatusNotFound, "Not found: "+r.URL.Path)
+		return
+	}
+
+	path = strings.TrimPrefix(path, "/service/local/")
+	parts := strings.SplitN(path, "/", 3)
+
+	if r.Method == "DELETE" {
+		if len(parts) == 3 && parts[0] == "repositories" && strings.HasPrefix(parts[2], "content/") {
+			s.deleteContent(w, r, parts[1], strings.TrimPrefix(parts[2], "content"))
+			return
+		}
+
+		fail(w, r, http.StatusMethodNotAllowed, "Can't DELETE "+r.URL.Path)
+		return
+	}
+
+	if r.Method != "GET" {
+		fail(w, r, http.StatusMethodNotAllowed, "Can't "+r.Method+" "+r.URL.Path)
+		return
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	switch {
+	case path == "status":
+		s.status(w, r)
+	case path == "repositories":
+		s.repositories(w, r)
+	case path == "repo_groups":
+		s.groups(w, r)
+	case path == "lucene/search":
+		s.search(w, r)
+	case path == "artifact/maven/resolve":
+		s.resolve(w, r)
+	case len(parts) == 2 && parts[0] == "repositories":
+		s.repositoryInfo(w, r, parts[1])
+	case len(parts) == 3 && parts[0] == "repositories" && parts[2] == "status":
+		s.repositoryStatus(w, r, parts[1])
+	case len(parts) == 3 && parts[0] == "repositories" && strings.HasPrefix(parts[2], "content/"):
+		s.content(w, r, parts[1], strings.TrimPrefix(parts[2], "content"))
+	default:
+		fail(w, r, http.StatusNotFound, "Not found: "+r.URL.Path)
+	}
+}
+
+// if the client asked for JSON; Nexus 2.x defaults to XML.
+func wantsJSON(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+// Nexus 2.x answers errors in JSON, if asked to, or with an HTML page, with
+// the message in a paragraph.
+func fail(w http.ResponseWriter, r *http.Request, status int, message string) {
+	if wantsJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"errors": []map[string]string{{"id": "*", "msg": message}},
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<html><body><h1>%v</h1><p>%v</p></body></html>",
+		http.StatusText(status), html.EscapeString(message))
+}
+
+// writes payload in the format the client asked for. The payloads' fields are
+// tagged for both formats, and the XML root element is in XMLName.
+func reply(w http.ResponseWriter, r *http.Request, payload interface{}) {
+	var body []byte
+	var err error
+	if wantsJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		body, err = json.Marshal(payload)
+	} else {
+		w.Header().Set("Content-Type", "application/xml")
+		body, err = xml.Marshal(payload)
+		body = append([]byte(xml.Header), body...)
+	}
+
+	if err != nil {
+		fail(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Write(body)
+}
+
+// The payloads below are tagged for both XML and JSON; since JSON has no
+// equivalent of XML's a>b paths, they nest like the JSON does.
+
+func (s *Server) status(w http.ResponseWriter, r *http.Request) {
+	version := s.Version
+	if version == "" {
+		version = DefaultVersion
+	}
+
+	type data struct {
+		Version string `xml:"version" json:"version"`
+		Edition string `xml:"editionShort" json:"editionShort"`
+	}
+
+	reply(w, r, struct {
+		XMLName xml.Name `xml:"status" json:"-"`
+		Data    data     `xml:"data" json:"data"`
+	}{Data: data{Version: version, Edition: "OSS"}})
+}
+
+type repositoryItem struct {
+	ID        string `xml:"id" json:"id"`
+	Name      string `xml:"name" json:"name"`
+	Type      string `xml:"repoType" json:"repoType"`
+	Policy    string `xml:"repoPolicy" json:"repoPolicy"`
+	Format    string `xml:"format" json:"format"`
+	RemoteURI string `xml:"remoteUri,omitempty" json:"remoteUri,omitempty"`
+}
+
+func (s *Server) repositories(w http.ResponseWriter, r *http.Request) {
+	items := []repositoryItem{}
+	for _, repo := range s.fixture.Repositories {
+		items = append(items, repositoryItem{repo.ID, repo.Name, repo.Type, repo.Policy, repo.Format, repo.RemoteURI})
+	}
+
+	reply(w, r, struct {
+		XMLName xml.Name         `xml:"repositories" json:"-"`
+		Data    []repositoryItem `xml:"data>repositories-item" json:"data"`
+	}{Data: items})
+}
+
+func (s *Server) groups(w http.ResponseWriter, r *http.Request) {
+	type member struct {
+		ID string `xml:"id" json:"id"`
+	}
+
+	type item struct {
+		ID      string   `xml:"id" json:"id"`
+		Name    string   `xml:"name" json:"name"`
+		Format  string   `xml:"format" json:"format"`
+		Policy  string   `xml:"repoPolicy" json:"repoPolicy"`
+		Members []member `xml:"repositories>repo-group-member" json:"repositories"`
+	}
+
+	items := []item{}
+	for _, group := range s.fixture.Groups {
+		members := []member{}
+		for _, id := range group.MemberIDs {
+			members = append(members, member{id})
+		}
+
+		items = append(items, item{group.ID, group.Name, group.Format, group.Policy, members})
+	}
+
+	reply(w, r, struct {
+		XMLName xml.Name `xml:"repo-group-list" json:"-"`
+		Data    []item   `xml:"data>repo-groups-item" json:"data"`
+	}{Data: items})
+}
+
+func (s *Server) repositoryInfo(w http.ResponseWriter, r *http.Request, id string) {
+	repo := s.fixture.repository(id)
+	if repo == nil {
+		fail(w, r, http.StatusNotFound, "Repository "+id+" not found")
+		return
+	}
+
+	writePolicy := "READ_ONLY"
+	if repo.Type == "hosted" {
+		writePolicy = "ALLOW_WRITE_ONCE"
+		if repo.Policy == "SNAPSHOT" {
+			writePolicy = "ALLOW_WRITE"
+		}
+	}
+
+	checksumPolicy := ""
+	if repo.Type == "proxy" {
+		checksumPolicy = "WARN"
+	}
+
+	type remoteStorage struct {
+		URL string `xml:"remoteStorageUrl" json:"remoteStorageUrl"`
+	}
+
+	type data struct {
+		ID                 string         `xml:"id" json:"id"`
+		Name               string         `xml:"name" json:"name"`
+		Type               string         `xml:"repoType" json:"repoType"`
+		Policy             string         `xml:"repoPolicy" json:"repoPolicy"`
+		Format             string         `xml:"format" json:"format"`
+		RemoteStorage      *remoteStorage `xml:"remoteStorage,omitempty" json:"remoteStorage,omitempty"`
+		WritePolicy        string         `xml:"writePolicy" json:"writePolicy"`
+		Browseable         bool           `xml:"browseable" json:"browseable"`
+		Indexable          bool           `xml:"indexable" json:"indexable"`
+		Exposed            bool           `xml:"exposed" json:"exposed"`
+		ContentResourceURI string         `xml:"contentResourceURI" json:"contentResourceURI"`
+		NotFoundCacheTTL   int            `xml:"notFoundCacheTTL" json:"notFoundCacheTTL"`
+		ChecksumPolicy     string         `xml:"checksumPolicy,omitempty" json:"checksumPolicy,omitempty"`
+	}
+
+	payload := data{
+		ID:                 repo.ID,
+		Name:               repo.Name,
+		Type:               repo.Type,
+		Policy:             repo.Policy,
+		Format:             repo.Format,
+		WritePolicy:        writePolicy,
+		Browseable:         true,
+		Indexable:          true,
+		Exposed:            true,
+		ContentResourceURI: s.URL + "/content/repositories/" + repo.ID,
+		NotFoundCacheTTL:   1440,
+		ChecksumPolicy:     checksumPolicy,
+	}
+
+	if repo.RemoteURI != "" {
+		payload.RemoteStorage = &remoteStorage{URL: repo.RemoteURI}
+	}
+
+	reply(w, r, struct {
+		XMLName xml.Name `xml:"repository" json:"-"`
+		Data    data     `xml:"data" json:"data"`
+	}{Data: payload})
+}
+
+func (s *Server) repositoryStatus(w http.ResponseWriter, r *http.Request, id string) {
+	repo := s.fixture.repository(id)
+	if repo == nil || repo.Type != "proxy" {
+		fail(w, r, http.StatusNotFound, "Proxy repository "+id+" not found")
+		return
+	}
+
+	type data struct {
+		ID           string `xml:"id" json:"id"`
+		RemoteStatus string `xml:"remoteStatus" json:"remoteStatus"`
+		ProxyMode    string `xml:"proxyMode" json:"proxyMode"`
+	}
+
+	reply(w, r, struct {
+		XMLName xml.Name `xml:"repositoryStatus" json:"-"`
+		Data    data     `xml:"data" json:"data"`
+	}{Data: data{ID: repo.ID, RemoteStatus: "AVAILABLE", ProxyMode: "ALLOW"}})
+}
+
+// the artifact at path in the given repository, or nil if there's none.
+func (s *Server) artifactAt(repositoryID string, path string) *Artifact {
+	for _, artifact := range s.fixture.Artifacts {
+		if artifact.RepositoryID == repositoryID && artifact.Path() == path {
+			return artifact
+		}
+	}
+
+	return nil
+}
+
+// lists a directory, serves a file, or describes it, depending on the path
+// and the query.
+func (s *Server) content(w http.ResponseWriter, r *http.Request, repositoryID string, path string) {
+	if s.fixture.repository(repositoryID) == nil {
+		fail(w, r, http.StatusNotFound, "Repository "+repositoryID+" not found")
+		return
+	}
+
+	if strings.HasSuffix(path, "/") {
+		s.list(w, r, repositoryID, path)
+		return
+	}
+
+	artifact := s.artifactAt(repositoryID, path)
+	if artifact == nil {
+		fail(w, r, http.StatusNotFound, "Item not found on path "+path+" in repository "+repositoryID)
+		return
+	}
+
+	if r.URL.Query().Get("describe") == "info" {
+		s.describe(w, r, artifact)
+		return
+	}
+
+	w.Header().Set("Content-Type", artifact.MimeType())
+	w.Header().Set("Content-Length", strconv.Itoa(len(artifact.Content)))
+	w.Write(artifact.Content)
+}
+
+func (s *Server) list(w http.ResponseWriter, r *http.Request, repositoryID string, dir string) {
+	type item struct {
+		ResourceURI  string `xml:"resourceURI" json:"resourceURI"`
+		RelativePath string `xml:"relativePath" json:"relativePath"`
+		Text         string `xml:"text" json:"text"`
+		Leaf         bool   `xml:"leaf" json:"leaf"`
+		SizeOnDisk   int    `xml:"sizeOnDisk" json:"sizeOnDisk"`
+	}
+
+	children := map[string]item{}
+	for _, artifact := range s.fixture.Artifacts {
+		path := artifact.Path()
+		if artifact.RepositoryID != repositoryID || !strings.HasPrefix(path, dir) {
+			continue
+		}
+
+		name := strings.SplitN(path[len(dir):], "/", 2)[0]
+		leaf := dir+name == path
+		size := -1
+		relativePath := dir + name + "/"
+		if leaf {
+			size, relativePath = len(artifact.Content), dir+name
+		}
+
+		children[name] = item{
+			ResourceURI:  s.URL + "/service/local/repositories/" + repositoryID + "/content" + relativePath,
+			RelativePath: relativePath,
+			Text:         name,
+			Leaf:         leaf,
+			SizeOnDisk:   size,
+		}
+	}
+
+	if len(children) == 0 && dir != "/" {
+		fail(w, r, http.StatusNotFound, "Item not found on path "+dir+" in repository "+repositoryID)
+		return
+	}
+
+	names := []string{}
+	for name := range children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	items := []item{}
+	for _, name := range names {
+		items = append(items, children[name])
+	}
+
+	reply(w, r, struct {
+		XMLName xml.Name `xml:"content" json:"-"`
+		Data    []item   `xml:"data>content-item" json:"data"`
+	}{Data: items})
+}
+
+func (s *Server) describe(w http.ResponseWriter, r *http.Request, artifact *Artifact) {
+	type repositoryURL struct {
+		RepositoryID string `xml:"repositoryId" json:"repositoryId"`
+		Path         string `xml:"path" json:"path"`
+		ArtifactURL  string `xml:"artifactUrl" json:"artifactUrl"`
+	}
+
+	type data struct {
+		PresentLocally bool            `xml:"presentLocally" json:"presentLocally"`
+		RepositoryID   string          `xml:"repositoryId" json:"repositoryId"`
+		RepositoryPath string          `xml:"repositoryPath" json:"repositoryPath"`
+		MimeType       string          `xml:"mimeType" json:"mimeType"`
+		Uploader       string          `xml:"uploader" json:"uploader"`
+		Uploaded       int64           `xml:"uploaded" json:"uploaded"`
+		LastChanged    int64           `xml:"lastChanged" json:"lastChanged"`
+		Size           int             `xml:"size" json:"size"`
+		Sha1Hash       string          `xml:"sha1Hash" json:"sha1Hash"`
+		Repositories   []repositoryURL `xml:"repositories>org.sonatype.nexus.rest.model.RepositoryUrlResource" json:"repositories"`
+	}
+
+	// the times are in seconds, as nexus.ArtifactInfo reads them
+	reply(w, r, struct {
+		XMLName xml.Name `xml:"org.sonatype.nexus.rest.model.ResourceInfoResourceResponse" json:"-"`
+		Data    data     `xml:"data" json:"data"`
+	}{Data: data{
+		PresentLocally: true,
+		RepositoryID:   artifact.RepositoryID,
+		RepositoryPath: artifact.Path(),
+		MimeType:       artifact.MimeType(),
+		Uploader:       artifact.Uploader,
+		Uploaded:       artifact.Uploaded.Unix(),
+		LastChanged:    artifact.Uploaded.Unix(),
+		Size:           len(artifact.Content),
+		Sha1Hash:       artifact.Sha1(),
+		Repositories: []repositoryURL{{
+			RepositoryID: artifact.RepositoryID,
+			Path:         artifact.Path(),
+			ArtifactURL:  s.URL + "/content/repositories/" + artifact.RepositoryID + artifact.Path(),
+		}},
+	}})
+}
+
+// deletes the artifact at path, or everything under it if it's a directory.
+func (s *Server) deleteContent(w http.ResponseWriter, r *http.Request, repositoryID string, path string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	kept := []*Artifact{}
+	for _, artifact := range s.fixture.Artifacts {
+		p := artifact.Path()
+		if artifact.RepositoryID == repositoryID && (p == path || strings.HasSuffix(path, "/") && strings.HasPrefix(p, path)) {
+			continue
+		}
+
+		kept = append(kept, artifact)
+	}
+
+	if len(kept) == len(s.fixture.Artifacts) {
+		fail(w, r, http.StatusNotFound, "Item not found on path "+path+" in repository "+repositoryID)
+		return
+	}
+
+	s.fixture.Artifacts = kept
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) resolve(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	extension := query.Get("e")
+	if extension == "" {
+		extension = "jar" // Maven's default
+	}
+
+	type data struct {
+		PresentLocally bool   `xml:"presentLocally" json:"presentLocally"`
+		GroupID        string `xml:"groupId" json:"groupId"`
+		ArtifactID     string `xml:"artifactId" json:"artifactId"`
+		Version        string `xml:"version" json:"version"`
+		Classifier     string `xml:"classifier,omitempty" json:"classifier,omitempty"`
+		Extension      string `xml:"extension" json:"extension"`
+		Snapshot       bool   `xml:"snapshot" json:"snapshot"`
+		RepositoryPath string `xml:"repositoryPath" json:"repositoryPath"`
+		Sha1           string `xml:"sha1" json:"sha1"`
+	}
+
+	for _, artifact := range s.fixture.Artifacts {
+		if artifact.GroupID == query.Get("g") && artifact.ArtifactID == query.Get("a") &&
+			artifact.Version == query.Get("v") && artifact.Classifier == query.Get("c") &&
+			artifact.Extension == extension && artifact.RepositoryID == query.Get("r") {
+			reply(w, r, struct {
+				XMLName xml.Name `xml:"artifact-resolution" json:"-"`
+				Data    data     `xml:"data" json:"data"`
+			}{Data: data{
+				PresentLocally: true,
+				GroupID:        artifact.GroupID,
+				ArtifactID:     artifact.ArtifactID,
+				Version:        artifact.Version,
+				Classifier:     artifact.Classifier,
+				Extension:      artifact.Extension,
+				Snapshot:       strings.HasSuffix(artifact.Version, "-SNAPSHOT"),
+				RepositoryPath: artifact.Path(),
+				Sha1:           artifact.Sha1(),
+			}})
+			return
+		}
+	}
+
+	fail(w, r, http.StatusNotFound, fmt.Sprintf("Unable to resolve artifact %v:%v:%v in repository %v",
+		query.Get("g"), query.Get("a"), query.Get("v"), query.Get("r")))
+}
@@ -0,0 +1,407 @@
+package nexustest_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"sbrubbles.org/go/nexus"
+	"sbrubbles.org/go/nexus/credentials"
+	"sbrubbles.org/go/nexus/nexustest"
+	"sbrubbles.org/go/nexus/search"
+)
+
+func fixture() *nexustest.Fixture {
+	lib := nexustest.NewArtifact("org.example:lib:jar:2.0@central", "lib 2.0")
+	lib.Classes = []string{"org.example.Lib", "org.example.internal.Helper"}
+
+	return &nexustest.Fixture{
+		Repositories: []*nexus.Repository{
+			{ID: "releases", Name: "Releases", Type: "hosted", Format: "maven2", Policy: "RELEASE"},
+			{ID: "snapshots", Name: "Snapshots", Type: "hosted", Format: "maven2", Policy: "SNAPSHOT"},
+			{ID: "central", Name: "Central", Type: "proxy", Format: "maven2", Policy: "RELEASE",
+				RemoteURI: "https://repo1.maven.org/maven2/"},
+		},
+		Groups: []*nexus.Group{
+			{ID: "public", Name: "Public", Format: "maven2", MemberIDs: []string{"releases", "snapshots", "central"}},
+		},
+		Artifacts: []*nexustest.Artifact{
+			nexustest.NewArtifact("com.acme:app:jar:1.0@releases", "app 1.0"),
+			nexustest.NewArtifact("com.acme:app:jar:sources:1.0@releases", "app 1.0 sources"),
+			nexustest.NewArtifact("com.acme:app:pom:1.0@releases", "<project/>"),
+			nexustest.NewArtifact("com.acme:app:jar:1.1@releases", "app 1.1"),
+			nexustest.NewArtifact("com.acme:app:jar:sources:1.1@releases", "app 1.1 sources"),
+			nexustest.NewArtifact("com.acme:app:jar:javadoc:1.1@releases", "app 1.1 javadoc"),
+			nexustest.NewArtifact("com.acme:app:pom:1.1@releases", "<project/>"),
+			nexustest.NewArtifact("com.acme:parent:pom:1.0@releases", "<project/>"),
+			nexustest.NewArtifact("com.acme:app:jar:1.2-SNAPSHOT@snapshots", "app 1.2-SNAPSHOT"),
+			nexustest.NewArtifact("com.acme:app:pom:1.2-SNAPSHOT@snapshots", "<project/>"),
+			lib,
+			nexustest.NewArtifact("org.example:lib:pom:2.0@central", "<project/>"),
+		},
+	}
+}
+
+// the artifacts' coordinates, sorted.
+func coordinatesOf(artifacts []*nexus.Artifact) []string {
+	result := []string{}
+	for _, artifact := range artifacts {
+		result = append(result, artifact.String())
+	}
+
+	sort.Strings(result)
+	return result
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+var searchTests = []struct {
+	criteria search.Criteria
+	expected []string
+}{
+	{
+		search.ByCoordinates{GroupID: "com.acme", Classifier: "sources"},
+		[]string{
+			"com.acme:app:jar:sources:1.0@releases",
+			"com.acme:app:jar:sources:1.1@releases",
+		},
+	},
+	{
+		search.ByCoordinates{ArtifactID: "app", Packaging: "pom"},
+		[]string{
+			"com.acme:app:pom:1.0@releases",
+			"com.acme:app:pom:1.1@releases",
+			"com.acme:app:pom:1.2-SNAPSHOT@snapshots",
+		},
+	},
+	{
+		search.ByCoordinates{GroupID: "ORG.*"},
+		[]string{
+			"org.example:lib:jar:2.0@central",
+			"org.example:lib:pom:2.0@central",
+		},
+	},
+	{
+		search.ByKeyword("parent"),
+		[]string{"com.acme:parent:pom:1.0@releases"},
+	},
+	{
+		search.InRepository{RepositoryID: "snapshots", Criteria: search.ByCoordinates{GroupID: "com.*"}},
+		[]string{
+			"com.acme:app:jar:1.2-SNAPSHOT@snapshots",
+			"com.acme:app:pom:1.2-SNAPSHOT@snapshots",
+		},
+	},
+	{
+		search.ByChecksum(nexustest.NewArtifact("g:a:jar:1@r", "lib 2.0").Sha1()),
+		[]string{"org.example:lib:jar:2.0@central"},
+	},
+	{
+		search.ByClassname("Lib"),
+		[]string{"org.example:lib:jar:2.0@central"},
+	},
+	{
+		search.ByClassname("org.example.*.Helper"),
+		[]string{"org.example:lib:jar:2.0@central"},
+	},
+	{
+		search.InRepository{RepositoryID: "central", Criteria: search.InRepository{
+			RepositoryID: "releases", Criteria: search.ByCoordinates{ArtifactID: "*i*"}}},
+		[]string{"org.example:lib:jar:2.0@central", "org.example:lib:pom:2.0@central"},
+	},
+	{
+		search.ByRepository("releases"),
+		[]string{
+			"com.acme:app:jar:1.0@releases",
+			"com.acme:app:jar:1.1@releases",
+			"com.acme:app:jar:javadoc:1.1@releases",
+			"com.acme:app:jar:sources:1.0@releases",
+			"com.acme:app:jar:sources:1.1@releases",
+			"com.acme:app:pom:1.0@releases",
+			"com.acme:app:pom:1.1@releases",
+			"com.acme:parent:pom:1.0@releases",
+		},
+	},
+}
+
+func TestServerSearches(t *testing.T) {
+	for _, quirky := range []bool{false, true} {
+		server := nexustest.NewUnstartedServer(fixture())
+		server.PageSize = 2 // forces some paging
+		server.Quirky = quirky
+		server.Start()
+		defer server.Close()
+
+		n := nexus.New(server.URL, credentials.None)
+		for _, test := range searchTests {
+			artifacts, err := n.Artifacts(test.criteria)
+			if err != nil {
+				t.Errorf("%v (quirky %v): unexpected error %v", test.criteria, quirky, err)
+				continue
+			}
+
+			if actual := coordinatesOf(artifacts); !equal(actual, test.expected) {
+				t.Errorf("%v (quirky %v): expected %v, got %v", test.criteria, quirky, test.expected, actual)
+			}
+		}
+	}
+}
+
+func TestServerSearchesEverything(t *testing.T) {
+	server := nexustest.NewServer(fixture())
+	defer server.Close()
+
+	artifacts, err := nexus.New(server.URL, credentials.None).Artifacts(search.All)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	if len(artifacts) != len(fixture().Artifacts) {
+		t.Errorf("Expected %v artifacts, got %v", len(fixture().Artifacts), coordinatesOf(artifacts))
+	}
+}
+
+// the lucene search's paging fields, plus the number of GAVs in the page.
+type page struct {
+	TotalCount     int      `xml:"totalCount"`
+	Count          int      `xml:"count"`
+	TooManyResults bool     `xml:"tooManyResults"`
+	GAVs           []string `xml:"data>artifact>version"`
+}
+
+func searchPage(t *testing.T, server *nexustest.Server, query string) page {
+	resp, err := http.Get(server.URL + "/service/local/lucene/search?" + query)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %v", resp.Status)
+	}
+
+	var p page
+	if err := xml.NewDecoder(resp.Body).Decode(&p); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	return p
+}
+
+func TestQuirkyServerCountsArtifactsButGroupsByGAV(t *testing.T) {
+	server := nexustest.NewUnstartedServer(fixture())
+	server.PageSize = 3
+	server.Quirky = true
+	server.Start()
+	defer server.Close()
+
+	// 1.0 has a jar and sources, and 1.1 a jar, sources and javadoc; the POMs
+	// don't count
+	first := searchPage(t, server, "g=com.acme&repositoryId=releases&a=app")
+	if first.TotalCount != 5 {
+		t.Errorf("Expected 5 results, got %v", first.TotalCount)
+	}
+
+	// so the second page starts in the middle of 1.1, which is in both pages
+	second := searchPage(t, server, "g=com.acme&repositoryId=releases&a=app&from=3")
+	if !equal(first.GAVs, []string{"1.0", "1.1"}) || !equal(second.GAVs, []string{"1.1"}) {
+		t.Errorf("Expected 1.1 split between pages, got %v and %v", first.GAVs, second.GAVs)
+	}
+}
+
+func TestServerRefusesTooManyResults(t *testing.T) {
+	server := nexustest.NewUnstartedServer(fixture())
+	server.MaxResults = 2
+	server.Start()
+	defer server.Close()
+
+	p := searchPage(t, server, "g=com.acme")
+	if !p.TooManyResults || len(p.GAVs) != 0 {
+		t.Errorf("Expected tooManyResults and no results, got %+v", p)
+	}
+}
+
+func TestServerRefusesEmptySearches(t *testing.T) {
+	server := nexustest.NewServer(fixture())
+	defer server.Close()
+
+	_, err := nexus.New(server.URL, credentials.None).Artifacts(search.ByKeyword(""))
+	if err == nil {
+		t.Errorf("Expected an error")
+	}
+}
+
+func TestServerDescribesArtifacts(t *testing.T) {
+	f := fixture()
+	uploaded := time.Date(2020, time.March, 1, 12, 0, 0, 0, time.UTC)
+	f.Artifacts[1].Uploaded = uploaded
+	f.Artifacts[1].Uploader = "deployment"
+
+	server := nexustest.NewServer(f)
+	defer server.Close()
+
+	info, err := nexus.New(server.URL, credentials.None).InfoOf(f.Artifacts[1].Artifact)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	if info.Sha1 != f.Artifacts[1].Sha1() || int(info.Size) != len("app 1.0 sources") ||
+		!info.Uploaded.Equal(uploaded) || info.Uploader != "deployment" {
+		t.Errorf("Unexpected info %v", info)
+	}
+
+	_, err = nexus.New(server.URL, credentials.None).InfoOf(&nexus.Artifact{
+		GroupID: "com.acme", ArtifactID: "app", Version: "9.9", Extension: "jar", RepositoryID: "releases"})
+	if e, ok := err.(nexus.Error); !ok || e.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected a 404, got %v", err)
+	}
+}
+
+func TestServerServesRepositoriesAndGroups(t *testing.T) {
+	server := nexustest.NewServer(fixture())
+	defer server.Close()
+
+	n := nexus.New(server.URL, credentials.None).(*nexus.Nexus2x)
+
+	repos, err := n.Repositories()
+	if err != nil || len(repos) != 3 || repos[2].RemoteURI != "https://repo1.maven.org/maven2/" {
+		t.Errorf("Unexpected repositories %v (error %v)", repos, err)
+	}
+
+	groups, err := n.Groups()
+	if err != nil || len(groups) != 1 {
+		t.Fatalf("Unexpected groups %v (error %v)", groups, err)
+	}
+
+	members, err := n.MembersOf(groups[0])
+	if err != nil || len(members) != 3 {
+		t.Errorf("Unexpected members %v (error %v)", members, err)
+	}
+
+	info, err := n.RepositoryInfo("central")
+	if err != nil || info.RemoteStatus != "AVAILABLE" || info.WritePolicy != "READ_ONLY" {
+		t.Errorf("Unexpected info %v (error %v)", info, err)
+	}
+}
+
+func TestServerDownloadsAndDeletes(t *testing.T) {
+	f := fixture()
+	server := nexustest.NewServer(f)
+	defer server.Close()
+
+	n := nexus.New(server.URL, credentials.None).(*nexus.Nexus2x)
+	artifact := f.Artifacts[0].Artifact
+
+	var buf bytes.Buffer
+	if err := n.Download(artifact, &buf); err != nil || buf.String() != "app 1.0" {
+		t.Errorf("Expected app 1.0, got %q (error %v)", buf.String(), err)
+	}
+
+	if err := n.DeleteVersion(artifact); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	artifacts, err := n.Artifacts(search.ByCoordinates{GroupID: "com.acme", Version: "1.0"})
+	if err != nil || len(artifacts) != 1 || artifacts[0].ArtifactID != "parent" {
+		t.Errorf("Expected only the parent left, got %v (error %v)", artifacts, err)
+	}
+
+	if left := len(server.Fixture().Artifacts); left != len(f.Artifacts)-3 {
+		t.Errorf("Expected %v artifacts left, got %v", len(f.Artifacts)-3, left)
+	}
+}
+
+func TestLoadFixture(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"releases/com/acme/app/1.0/app-1.0.jar":                    "jar",
+		"releases/com/acme/app/1.0/app-1.0-sources.jar":            "sources",
+		"releases/com/acme/app/1.0/app-1.0.jar.sha1":               "ignored",
+		"releases/com/acme/app/maven-metadata.xml":                 "ignored",
+		"snapshots/com/acme/app/2.0-SNAPSHOT/app-2.0-SNAPSHOT.pom": "pom",
+	}
+
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := nexustest.LoadFixture(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	actual := []string{}
+	for _, artifact := range f.Artifacts {
+		actual = append(actual, artifact.String())
+	}
+
+	expected := []string{
+		"com.acme:app:jar:1.0@releases",
+		"com.acme:app:jar:sources:1.0@releases",
+		"com.acme:app:pom:2.0-SNAPSHOT@snapshots",
+	}
+	if !equal(actual, expected) {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+
+	if len(f.Repositories) != 2 || f.Repositories[0].Policy != "RELEASE" || f.Repositories[1].Policy != "SNAPSHOT" {
+		t.Errorf("Unexpected repositories %v", f.Repositories)
+	}
+}
+
+func TestServerEchoesThePageSizeOnlyWhenAskedFor(t *testing.T) {
+	server := nexustest.NewUnstartedServer(fixture())
+	server.PageSize = 3
+	server.Start()
+	defer server.Close()
+
+	for query, expected := range map[string]int{
+		"g=com.acme":          -1,
+		"g=com.acme&count=2":  2,
+		"g=com.acme&count=50": 3,
+	} {
+		if p := searchPage(t, server, query); p.Count != expected {
+			t.Errorf("%v: expected the count %v, got %v", query, expected, p.Count)
+		}
+	}
+}
+
+func TestServerTakesGroupsAndArtifactsAsPrefixesUnlessExact(t *testing.T) {
+	server := nexustest.NewServer(fixture())
+	defer server.Close()
+
+	for query, expected := range map[string]int{
+		"g=com.ac&a=par":                 1,
+		"g=com.ac&a=par&exact=true":      0,
+		"g=com.acme&a=parent&exact=true": 1,
+		"g=com.ac*&a=par*&exact=true":    1,
+	} {
+		if p := searchPage(t, server, query); len(p.GAVs) != expected {
+			t.Errorf("%v: expected %v GAVs, got %v", query, expected, p.GAVs)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package nexus
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"sbrubbles.org/go/nexus/metrics"
+)
+
+// the kind of endpoint the given request goes to.
+func endpointOf(method string, path string, query map[string]string) metrics.Endpoint {
+	switch {
+	case strings.HasPrefix(path, "service/local/lucene/search"):
+		return metrics.Search
+	case strings.HasPrefix(path, "service/local/artifact/maven/resolve"):
+		return metrics.Resolve
+	case strings.HasPrefix(path, "service/local/artifact/maven/content"): // deployments
+		return metrics.Content
+	case strings.HasPrefix(path, "service/local/repositories/") && strings.Contains(path, "/content"):
+		if query["describe"] == "info" {
+			return metrics.Info
+		}
+
+		if method == "GET" && strings.HasSuffix(path, "/") {
+			return metrics.ContentListing
+		}
+
+		return metrics.Content
+	case strings.HasPrefix(path, "service/local/repositories"),
+		strings.HasPrefix(path, "service/local/repo_groups"):
+		return metrics.Repositories
+	}
+
+	return metrics.Other
+}
+
+// tells this client's Observer about the given request, which went to path
+// with query, started at start and took the given attempts. Requests without a
+// response are observed right away; the others, when their body is closed, so
+// the response is returned with its body wrapped.
+func (nexus Nexus2x) observe(request *http.Request, path string, query map[string]string, start time.Time,
+	attempts int, response *http.Response, err error) *http.Response {
+	if nexus.Observer == nil {
+		return response
+	}
+
+	observed := metrics.Request{
+		Endpoint: endpointOf(request.Method, path, query),
+		Method:   request.Method,
+		Latency:  time.Since(start),
+		Retries:  attempts - 1,
+		Err:      err,
+	}
+
+	if response == nil {
+		nexus.Observer.Observe(observed)
+		return nil
+	}
+
+	observed.StatusCode = response.StatusCode
+	response.Body = &observedBody{ReadCloser: response.Body, request: observed, observer: nexus.Observer}
+	return response
+}
+
+// a response body which counts the bytes read, and tells the observer about
+// its request when closed.
+type observedBody struct {
+	io.ReadCloser
+
+	request  metrics.Request
+	observer metrics.Observer
+	once     sync.Once
+}
+
+// Read implements the io.Reader interface.
+func (body *observedBody) Read(p []byte) (int, error) {
+	n, err := body.ReadCloser.Read(p)
+	body.request.Bytes += int64(n)
+
+	return n, err
+}
+
+// Close implements the io.Closer interface.
+func (body *observedBody) Close() error {
+	err := body.ReadCloser.Close()
+	body.once.Do(func() { body.observer.Observe(body.request) })
+
+	return err
+}
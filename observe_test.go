@@ -0,0 +1,98 @@
+package nexus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"sbrubbles.org/go/nexus/credentials"
+	"sbrubbles.org/go/nexus/metrics"
+	"sbrubbles.org/go/nexus/search"
+)
+
+var endpointTests = []struct {
+	method   string
+	path     string
+	query    map[string]string
+	expected metrics.Endpoint
+}{
+	{"GET", "service/local/lucene/search", map[string]string{"q": "a"}, metrics.Search},
+	{"GET", "service/local/artifact/maven/resolve", nil, metrics.Resolve},
+	{"GET", "service/local/repositories/releases/content/com/acme/app/1.0/app-1.0.jar", map[string]string{"describe": "info"}, metrics.Info},
+	{"GET", "service/local/repositories/releases/content/", nil, metrics.ContentListing},
+	{"GET", "service/local/repositories/releases/content/com/acme/app/1.0/app-1.0.jar", nil, metrics.Content},
+	{"DELETE", "service/local/repositories/releases/content/com/acme/", nil, metrics.Content},
+	{"POST", "service/local/artifact/maven/content", nil, metrics.Content},
+	{"GET", "service/local/repositories", nil, metrics.Repositories},
+	{"GET", "service/local/repositories/central", nil, metrics.Repositories},
+	{"GET", "service/local/repositories/central/status", nil, metrics.Repositories},
+	{"GET", "service/local/repo_groups", nil, metrics.Repositories},
+	{"GET", "service/local/status", nil, metrics.Other},
+}
+
+func TestEndpointOf(t *testing.T) {
+	for _, test := range endpointTests {
+		if actual := endpointOf(test.method, test.path, test.query); actual != test.expected {
+			t.Errorf("%v %v: expected %v, got %v", test.method, test.path, test.expected, actual)
+		}
+	}
+}
+
+func TestObserverSeesEveryRequest(t *testing.T) {
+	var failures int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/lucene/search"):
+			lucenePages(lucenePage("g", "a", "1", "releases", "jar")).ServeHTTP(w, r)
+		case strings.HasSuffix(r.URL.Path, "/repositories") && atomic.AddInt32(&failures, 1) == 1:
+			w.WriteHeader(http.StatusServiceUnavailable) // once
+		case strings.HasSuffix(r.URL.Path, "/repositories"):
+			w.Write([]byte("<repositories><data></data></repositories>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	collector := metrics.NewCollector()
+	n := Nexus2x{
+		URL:         server.URL,
+		Credentials: credentials.None,
+		HTTPClient:  &http.Client{},
+		Retry:       &RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond},
+		Observer:    collector,
+	}
+
+	if _, err := n.Artifacts(search.ByKeyword("a")); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	if _, err := n.Repositories(); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	if _, err := n.InfoOf(&Artifact{GroupID: "g", ArtifactID: "a", Version: "1", Extension: "jar", RepositoryID: "releases"}); err == nil {
+		t.Fatalf("Expected an error")
+	}
+
+	snapshot := collector.Snapshot()
+
+	// no counts, so a page with the GAV, and an empty one
+	searches := snapshot[metrics.Search]
+	if searches.Requests != 2 || searches.Statuses[200] != 2 || searches.Bytes == 0 || searches.Latency.Count != 2 {
+		t.Errorf("Unexpected search stats %+v", searches)
+	}
+
+	repositories := snapshot[metrics.Repositories]
+	if repositories.Requests != 1 || repositories.Retries != 1 || repositories.Statuses[200] != 1 {
+		t.Errorf("Unexpected repositories stats %+v", repositories)
+	}
+
+	resolves := snapshot[metrics.Resolve]
+	if resolves.Requests != 1 || resolves.Failures != 1 || resolves.Statuses[404] != 1 {
+		t.Errorf("Unexpected resolve stats %+v", resolves)
+	}
+}
@@ -0,0 +1,290 @@
+package nexus_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"sbrubbles.org/go/nexus"
+	"sbrubbles.org/go/nexus/credentials"
+	"sbrubbles.org/go/nexus/nexustest"
+	"sbrubbles.org/go/nexus/search"
+)
+
+// records the 'from' of every lucene search.
+type searchRecorder struct {
+	mutex sync.Mutex
+	froms []int
+}
+
+func (r *searchRecorder) RoundTrip(request *http.Request) (*http.Response, error) {
+	if strings.HasSuffix(request.URL.Path, "/lucene/search") {
+		from, _ := strconv.Atoi(request.URL.Query().Get("from"))
+
+		r.mutex.Lock()
+		r.froms = append(r.froms, from)
+		r.mutex.Unlock()
+	}
+
+	return http.DefaultTransport.RoundTrip(request)
+}
+
+// the recorded froms, sorted.
+func (r *searchRecorder) sorted() []int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	froms := append([]int{}, r.froms...)
+	sort.Ints(froms)
+
+	return froms
+}
+
+// GAVs with POMs which count and POMs which don't, in two pages of two
+// artifacts each. Nexus (and a quirky nexustest.Server) pages by artifact,
+// without the POMs of GAVs which have something else.
+func pagingFixture() *nexustest.Fixture {
+	return &nexustest.Fixture{
+		Repositories: []*nexus.Repository{
+			{ID: "releases", Name: "Releases", Type: "hosted", Format: "maven2", Policy: "RELEASE"},
+		},
+		Artifacts: []*nexustest.Artifact{
+			nexustest.NewArtifact("com.acme:app:jar:1.0@releases", "app"),         // 0
+			nexustest.NewArtifact("com.acme:app:jar:sources:1.0@releases", "src"), // 1
+			nexustest.NewArtifact("com.acme:app:pom:1.0@releases", "<project/>"),  // along with 0
+			nexustest.NewArtifact("com.acme:lib:jar:1.0@releases", "lib"),         // 2
+			nexustest.NewArtifact("com.acme:lib:pom:1.0@releases", "<project/>"),  // along with 2
+			nexustest.NewArtifact("com.acme:parent:pom:1.0@releases", "<pom/>"),   // 3, since it's alone
+		},
+	}
+}
+
+var pagingExpected = []string{
+	"com.acme:app:jar:1.0@releases",
+	"com.acme:app:jar:sources:1.0@releases",
+	"com.acme:app:pom:1.0@releases",
+	"com.acme:lib:jar:1.0@releases",
+	"com.acme:lib:pom:1.0@releases",
+	"com.acme:parent:pom:1.0@releases",
+}
+
+// a quirky nexustest.Server with the paging fixture, not started, so its
+// handler can be wrapped.
+func pagingServer() *nexustest.Server {
+	server := nexustest.NewUnstartedServer(pagingFixture())
+	server.PageSize = 2
+	server.Quirky = true
+
+	return server
+}
+
+func TestSearchPagesInParallelByArtifactsWithoutPOMs(t *testing.T) {
+	server := pagingServer()
+	server.Start()
+	defer server.Close()
+
+	recorder := &searchRecorder{}
+	n := nexus.Nexus2x{URL: server.URL, Credentials: credentials.None, HTTPClient: &http.Client{Transport: recorder}}
+
+	artifacts, err := n.Artifacts(search.ByCoordinates{GroupID: "com.acme"})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	if actual := sortedCoordinates(artifacts); !reflect.DeepEqual(actual, pagingExpected) {
+		t.Errorf("Expected %v, got %v", pagingExpected, actual)
+	}
+
+	// 4 artifacts count, so two pages, and nothing else
+	if froms := recorder.sorted(); !reflect.DeepEqual(froms, []int{0, 2}) {
+		t.Errorf("Expected the pages at 0 and 2, got %v", froms)
+	}
+}
+
+var totalCountRe = regexp.MustCompile(`<totalCount>\d+</totalCount>`)
+
+func TestSearchFallsBackToSequentialPagingWhenTheCountsDontAddUp(t *testing.T) {
+	// as if something was deployed after the first page
+	fake := pagingServer()
+	defer fake.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("from") == "0" || !strings.HasSuffix(r.URL.Path, "/lucene/search") {
+			fake.Config.Handler.ServeHTTP(w, r)
+			return
+		}
+
+		recorder := httptest.NewRecorder()
+		fake.Config.Handler.ServeHTTP(recorder, r)
+		w.Write(totalCountRe.ReplaceAll(recorder.Body.Bytes(), []byte("<totalCount>5</totalCount>")))
+	}))
+	defer server.Close()
+
+	recorder := &searchRecorder{}
+	n := nexus.Nexus2x{URL: server.URL, Credentials: credentials.None, HTTPClient: &http.Client{Transport: recorder}}
+
+	artifacts, err := n.Artifacts(search.ByCoordinates{GroupID: "com.acme"})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	if actual := sortedCoordinates(artifacts); !reflect.DeepEqual(actual, pagingExpected) {
+		t.Errorf("Expected %v, got %v", pagingExpected, actual)
+	}
+
+	// the two pages, and then starting over, advancing by the GAVs: app at 0,
+	// app and lib at 1, parent at 3, and nothing at 4
+	if froms := recorder.sorted(); !reflect.DeepEqual(froms, []int{0, 0, 1, 2, 3, 4}) {
+		t.Errorf("Unexpected pages %v", froms)
+	}
+}
+
+// lots of groups, some of them prefixes of others, in two repositories.
+func splitFixture() *nexustest.Fixture {
+	fixture := pagingFixture()
+	fixture.Repositories = append(fixture.Repositories,
+		&nexus.Repository{ID: "thirdparty", Name: "3rd party", Type: "hosted", Format: "maven2", Policy: "RELEASE"})
+
+	for _, coordinates := range []string{
+		"org:root:jar:1@releases",
+		"org.a:x:jar:1@releases",
+		"org.a:y:jar:1@releases",
+		"org.a:z:jar:1@thirdparty",
+		"org.ab:x:jar:1@releases",
+		"org.b:x:jar:1@thirdparty",
+		"org.b:x:jar:2@thirdparty",
+		"org.b.c:x:jar:1@releases",
+		"org.b.c:x:pom:1@releases",
+		"org.b-c:Y:jar:1@releases",
+		"org.b_c:z:jar:1@releases",
+		"org.c:z:jar:1@releases",
+		"org0:x:jar:1@releases",
+	} {
+		fixture.Artifacts = append(fixture.Artifacts, nexustest.NewArtifact(coordinates, coordinates))
+	}
+
+	return fixture
+}
+
+func TestSearchSplitsQueriesWithTooManyResults(t *testing.T) {
+	unlimited := nexustest.NewServer(splitFixture())
+	defer unlimited.Close()
+
+	limited := nexustest.NewUnstartedServer(splitFixture())
+	limited.PageSize = 2
+	limited.Quirky = true
+	limited.MaxResults = 2
+	limited.Start()
+	defer limited.Close()
+
+	for _, criteria := range []search.Criteria{
+		search.ByCoordinates{GroupID: "org*"},
+		search.ByCoordinates{GroupID: "org.b*", Packaging: "jar"},
+		search.ByCoordinates{ArtifactID: "x"},
+		search.ByRepository("releases"),
+		search.All,
+		// the refined searches are exact, which mustn't change how the rest
+		// of the search matches
+		search.ByCoordinates{GroupID: "org*", Version: "1"},
+		search.ByCoordinates{GroupID: "com*", Version: "1.0", Packaging: "jar"},
+		search.ByCoordinates{GroupID: "com", Classifier: "sources"},
+	} {
+		expected, err := nexus.Nexus2x{URL: unlimited.URL, Credentials: credentials.None, HTTPClient: &http.Client{}}.
+			Artifacts(criteria)
+		if err != nil {
+			t.Fatalf("%v: unexpected error %v", criteria, err)
+		}
+
+		actual, err := nexus.Nexus2x{URL: limited.URL, Credentials: credentials.None, HTTPClient: &http.Client{}}.
+			Artifacts(criteria)
+		if err != nil {
+			t.Errorf("%v: unexpected error %v", criteria, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(sortedCoordinates(expected), sortedCoordinates(actual)) {
+			t.Errorf("%v: expected %v, got %v", criteria, sortedCoordinates(expected), sortedCoordinates(actual))
+		}
+	}
+}
+
+func TestSearchFailsWithTooManyResultsWhenItCantBeSplit(t *testing.T) {
+	server := nexustest.NewUnstartedServer(splitFixture())
+	server.MaxResults = 1
+	server.Start()
+	defer server.Close()
+
+	n := nexus.Nexus2x{URL: server.URL, Credentials: credentials.None, HTTPClient: &http.Client{}}
+
+	for _, criteria := range []search.Criteria{
+		search.ByCoordinates{GroupID: "org.b", ArtifactID: "x"}, // two versions
+		search.ByKeyword("x"),
+	} {
+		_, err := n.Artifacts(criteria)
+
+		var tooMany *nexus.TooManyResultsError
+		if !errors.As(err, &tooMany) || tooMany.Parameters["repositoryId"] == "" {
+			t.Errorf("%v: expected a *TooManyResultsError in a repository, got %v", criteria, err)
+		}
+	}
+
+	// a best effort search goes on, and tells what failed
+	n.BestEffort = true
+
+	artifacts, err := n.Artifacts(search.ByKeyword("root"))
+	if err != nil || len(artifacts) != 1 {
+		t.Errorf("Expected org:root, got %v (error %v)", artifacts, err)
+	}
+
+	// two in releases, but only one in thirdparty
+	artifacts, err = n.Artifacts(search.ByKeyword("z"))
+	var multi *nexus.MultiError
+	if !errors.As(err, &multi) || len(multi.Errors) != 1 || multi.Errors[0].RepositoryID != "releases" ||
+		len(artifacts) != 1 || artifacts[0].RepositoryID != "thirdparty" {
+		t.Errorf("Expected org.a:z and a *MultiError for releases, got %v (error %v)", artifacts, err)
+	}
+}
+
+func TestWalkFuncsMayUseASingleSlotLimiter(t *testing.T) {
+	server := nexustest.NewUnstartedServer(pagingFixture())
+	server.PageSize = 2
+	server.Start()
+	defer server.Close()
+
+	n := nexus.Nexus2x{
+		URL:            server.URL,
+		Credentials:    credentials.None,
+		HTTPClient:     &http.Client{},
+		MaxParallelism: 4,
+		Limiter:        nexus.NewLimiter(1),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	infos := 0
+	err := n.WalkArtifactsContext(ctx, search.ByCoordinates{GroupID: "com.acme"}, func(artifact *nexus.Artifact) error {
+		if _, err := n.InfoOfContext(ctx, artifact); err != nil {
+			return err
+		}
+
+		infos++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	if infos != len(server.Fixture().Artifacts) {
+		t.Errorf("Expected %v artifacts, got %v", len(server.Fixture().Artifacts), infos)
+	}
+}
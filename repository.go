@@ -1,10 +1,17 @@
 package nexus
 
-import "encoding/xml"
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+)
 
 // Repository is a non-group Nexus repository. Nexus actually provides a bit
 // more data, but this should be enough for most uses. Groups aren't considered
-// repositories by Nexus' API; there's a separate call for them.
+// repositories by Nexus' API; there's a separate call for them (see
+// Nexus2x.Groups).
 type Repository struct {
 	ID        string // e.g. releases
 	Name      string // e.g. Releases
@@ -31,23 +38,41 @@ func (repo Repository) String() string {
 // repos is here to help unmarshal Nexus' responses about repositories.
 type repos []*Repository
 
+// Nexus' list of repositories.
+type reposPayload struct {
+	Data []struct {
+		ID        string `xml:"id" json:"id"`
+		Name      string `xml:"name" json:"name"`
+		Type      string `xml:"repoType" json:"repoType"`
+		Policy    string `xml:"repoPolicy" json:"repoPolicy"`
+		Format    string `xml:"format" json:"format"`
+		RemoteURI string `xml:"remoteUri" json:"remoteUri"`
+	} `xml:"data>repositories-item" json:"data"`
+}
+
 // UnmarshalXML implements the xml.Unmarshaler interface.
 func (r *repos) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
-	var payload struct {
-		Data []struct {
-			ID        string `xml:"id"`
-			Name      string `xml:"name"`
-			Type      string `xml:"repoType"`
-			Policy    string `xml:"repoPolicy"`
-			Format    string `xml:"format"`
-			RemoteURI string `xml:"remoteUri"`
-		} `xml:"data>repositories-item"`
+	var payload reposPayload
+	if err := d.DecodeElement(&payload, &start); err != nil {
+		return err
 	}
 
-	if err := d.DecodeElement(&payload, &start); err != nil {
+	r.fill(payload)
+	return nil
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (r *repos) UnmarshalJSON(data []byte) error {
+	var payload reposPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
 		return err
 	}
 
+	r.fill(payload)
+	return nil
+}
+
+func (r *repos) fill(payload reposPayload) {
 	for _, repo := range payload.Data {
 		newRepo := &Repository{
 			ID:        repo.ID,
@@ -60,6 +85,179 @@ func (r *repos) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 
 		*r = append(*r, newRepo)
 	}
+}
+
+// Group is a Nexus repository group, which Nexus' API doesn't consider a
+// repository. A group aggregates other repositories (or groups), its members,
+// in a given order.
+type Group struct {
+	ID        string   // e.g. public
+	Name      string   // e.g. Public Repositories
+	Format    string   // e.g. maven2, maven1...
+	Policy    string   // e.g. RELEASE, SNAPSHOT; Nexus may leave it empty
+	MemberIDs []string // e.g. [releases snapshots central], in order
+}
+
+// String implements the fmt.Stringer interface.
+func (group Group) String() string {
+	return group.ID + " ('" + group.Name + "'){ group, " +
+		group.Format + " format, " +
+		group.Policy + " policy, members " + strings.Join(group.MemberIDs, ", ") + " }"
+}
 
+// groups is here to help unmarshal Nexus' responses about groups.
+type groups []*Group
+
+// Nexus' list of groups.
+type groupsPayload struct {
+	Data []struct {
+		ID      string `xml:"id" json:"id"`
+		Name    string `xml:"name" json:"name"`
+		Format  string `xml:"format" json:"format"`
+		Policy  string `xml:"repoPolicy" json:"repoPolicy"`
+		Members []struct {
+			ID string `xml:"id" json:"id"`
+		} `xml:"repositories>repo-group-member" json:"repositories"`
+	} `xml:"data>repo-groups-item" json:"data"`
+}
+
+// UnmarshalXML implements the xml.Unmarshaler interface.
+func (g *groups) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var payload groupsPayload
+	if err := d.DecodeElement(&payload, &start); err != nil {
+		return err
+	}
+
+	g.fill(payload)
 	return nil
 }
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (g *groups) UnmarshalJSON(data []byte) error {
+	var payload groupsPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return err
+	}
+
+	g.fill(payload)
+	return nil
+}
+
+func (g *groups) fill(payload groupsPayload) {
+	for _, group := range payload.Data {
+		members := []string{}
+		for _, member := range group.Members {
+			members = append(members, member.ID)
+		}
+
+		newGroup := &Group{
+			ID:        group.ID,
+			Name:      group.Name,
+			Format:    group.Format,
+			Policy:    group.Policy,
+			MemberIDs: members,
+		}
+
+		*g = append(*g, newGroup)
+	}
+}
+
+// RepositoryInfo holds a repository's detailed settings. There are no
+// constructors; use Nexus2x.RepositoryInfo to fetch and build instances. The
+// proxy-related fields are empty for non-proxy repositories.
+type RepositoryInfo struct {
+	*Repository
+
+	WritePolicy        string        // e.g. ALLOW_WRITE, ALLOW_WRITE_ONCE, READ_ONLY
+	Browseable         bool          // if the contents can be browsed
+	Indexable          bool          // if the contents are indexed for searches
+	Exposed            bool          // if the repository is published at its URL
+	ContentResourceURI string        // e.g. http://somewhere.com/nexus/content/repositories/releases
+	NotFoundCacheTTL   time.Duration // how long Nexus remembers that something isn't there
+	ChecksumPolicy     string        // e.g. IGNORE, WARN, STRICT_IF_EXISTS, STRICT
+	RemoteStatus       string        // e.g. AVAILABLE, UNAVAILABLE, UNKNOWN
+	ProxyMode          string        // e.g. ALLOW, BLOCKED_AUTO, BLOCKED_MANUAL
+	AutoBlockActive    bool          // if Nexus blocks the proxy when the remote is down
+}
+
+// String implements the fmt.Stringer interface.
+func (info RepositoryInfo) String() string {
+	return fmt.Sprintf("%v : [%v, browseable %v, indexable %v, exposed %v, remote %v, proxy mode %v]",
+		info.Repository, info.WritePolicy, info.Browseable, info.Indexable,
+		info.Exposed, info.RemoteStatus, info.ProxyMode)
+}
+
+// a repository's settings, as Nexus sends them.
+type repositoryInfoPayload struct {
+	Data struct {
+		ID            string `xml:"id" json:"id"`
+		Name          string `xml:"name" json:"name"`
+		Type          string `xml:"repoType" json:"repoType"`
+		Policy        string `xml:"repoPolicy" json:"repoPolicy"`
+		Format        string `xml:"format" json:"format"`
+		RemoteStorage struct {
+			URL string `xml:"remoteStorageUrl" json:"remoteStorageUrl"`
+		} `xml:"remoteStorage" json:"remoteStorage"`
+		WritePolicy        string `xml:"writePolicy" json:"writePolicy"`
+		Browseable         bool   `xml:"browseable" json:"browseable"`
+		Indexable          bool   `xml:"indexable" json:"indexable"`
+		Exposed            bool   `xml:"exposed" json:"exposed"`
+		ContentResourceURI string `xml:"contentResourceURI" json:"contentResourceURI"`
+		NotFoundCacheTTL   int64  `xml:"notFoundCacheTTL" json:"notFoundCacheTTL"`
+		ChecksumPolicy     string `xml:"checksumPolicy" json:"checksumPolicy"`
+		AutoBlockActive    bool   `xml:"autoBlockActive" json:"autoBlockActive"`
+	} `xml:"data" json:"data"`
+}
+
+// UnmarshalXML implements the xml.Unmarshaler interface. It reads Nexus'
+// repository settings only; RepositoryInfoContext fetches the status from
+// repositories/{id}/status.
+func (info *RepositoryInfo) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var payload repositoryInfoPayload
+	if err := d.DecodeElement(&payload, &start); err != nil {
+		return err
+	}
+
+	info.fill(payload)
+	return nil
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface, like UnmarshalXML.
+func (info *RepositoryInfo) UnmarshalJSON(data []byte) error {
+	var payload repositoryInfoPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return err
+	}
+
+	info.fill(payload)
+	return nil
+}
+
+func (info *RepositoryInfo) fill(payload repositoryInfoPayload) {
+	data := payload.Data
+	info.Repository = &Repository{
+		ID:        data.ID,
+		Name:      data.Name,
+		Type:      data.Type,
+		Format:    data.Format,
+		Policy:    data.Policy,
+		RemoteURI: data.RemoteStorage.URL,
+	}
+
+	info.WritePolicy = data.WritePolicy
+	info.Browseable = data.Browseable
+	info.Indexable = data.Indexable
+	info.Exposed = data.Exposed
+	info.ContentResourceURI = data.ContentResourceURI
+	info.NotFoundCacheTTL = time.Duration(data.NotFoundCacheTTL) * time.Minute // Nexus counts in minutes
+	info.ChecksumPolicy = data.ChecksumPolicy
+	info.AutoBlockActive = data.AutoBlockActive
+}
+
+// a proxy repository's remote status, as Nexus sends it.
+type repositoryStatus struct {
+	Data struct {
+		RemoteStatus string `xml:"remoteStatus" json:"remoteStatus"`
+		ProxyMode    string `xml:"proxyMode" json:"proxyMode"`
+	} `xml:"data" json:"data"`
+}
@@ -0,0 +1,163 @@
+package nexus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy says how a Nexus2x retries a failed GET (the only kind of
+// request which is always safe to retry). The delay between attempts grows
+// exponentially from BaseDelay, with some jitter to avoid hammering Nexus in
+// lockstep, unless Nexus says how long to wait with a Retry-After header.
+type RetryPolicy struct {
+	MaxAttempts int           // e.g. 5, counting the first; < 2 means no retries
+	BaseDelay   time.Duration // e.g. 500ms, the delay before the first retry
+	MaxDelay    time.Duration // e.g. 30s, a cap for the backoff; 0 means no cap
+
+	// Decides if an attempt which ended with the given response or error
+	// (only one of them is non-nil) should be retried. Nil means
+	// DefaultRetryable.
+	Retryable func(response *http.Response, err error) bool
+}
+
+// DefaultRetryPolicy is a sensible RetryPolicy for long-running searches.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    30 * time.Second,
+	Retryable:   DefaultRetryable,
+}
+
+// DefaultRetryable retries on transient network errors (timeouts, connection
+// resets, or the connection closed midway, but not the context's) and on the
+// statuses a proxy or an overloaded Nexus usually responds with: 429, 502, 503
+// and 504. Other errors, like a bad URL or an unknown host, won't go away on
+// their own.
+func DefaultRetryable(response *http.Response, err error) bool {
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return false
+		}
+
+		// every error from an http.Client is a net.Error (a *url.Error), so
+		// only its timeouts count
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return true
+		}
+
+		return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+	}
+
+	switch response.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
+
+// RetryError is returned when a request failed even after being retried. It
+// wraps the error of the last attempt.
+type RetryError struct {
+	Attempts int   // e.g. 5
+	Err      error // the last attempt's error
+}
+
+// Error implements the error interface.
+func (err RetryError) Error() string {
+	return fmt.Sprintf("%v (after %v attempts)", err.Err, err.Attempts)
+}
+
+// Unwrap returns the last attempt's error.
+func (err RetryError) Unwrap() error {
+	return err.Err
+}
+
+// wraps err in a *RetryError, if there was more than one attempt.
+func withAttempts(attempts int, err error) error {
+	if attempts < 2 {
+		return err
+	}
+
+	return &RetryError{Attempts: attempts, Err: err}
+}
+
+// if, after the given number of attempts, ending in response or err, there
+// should be another one. Safe to call on a nil *RetryPolicy.
+func (policy *RetryPolicy) shouldRetry(attempts int, response *http.Response, err error) bool {
+	if policy == nil || attempts >= policy.MaxAttempts {
+		return false
+	}
+
+	retryable := policy.Retryable
+	if retryable == nil {
+		retryable = DefaultRetryable
+	}
+
+	return retryable(response, err)
+}
+
+// how long to wait before the next attempt, after the given number of
+// attempts, the last ending in response (which may be nil).
+func (policy *RetryPolicy) delay(attempts int, response *http.Response) time.Duration {
+	if after, ok := retryAfter(response); ok {
+		return after
+	}
+
+	delay := policy.BaseDelay
+	if shift := uint(attempts - 1); delay > 0 && shift >= uint(bits.LeadingZeros64(uint64(delay))) {
+		delay = math.MaxInt64 // the shift would overflow
+	} else {
+		delay <<= shift
+	}
+
+	if policy.MaxDelay > 0 && delay > policy.MaxDelay {
+		delay = policy.MaxDelay
+	}
+
+	// "equal jitter": somewhere between half the delay and the whole of it
+	half := int64(delay / 2)
+	if half <= 0 {
+		return delay
+	}
+
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+// reads the Retry-After header, which can be in seconds or an HTTP date.
+func retryAfter(response *http.Response) (time.Duration, bool) {
+	if response == nil {
+		return 0, false
+	}
+
+	header := response.Header.Get("Retry-After")
+	if header == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(header); err == nil {
+		if delay := time.Until(date); delay > 0 {
+			return delay, true
+		}
+
+		return 0, true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,151 @@
+package nexus
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"sbrubbles.org/go/nexus/credentials"
+)
+
+func TestFetchRetriesUntilItWorks(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Write([]byte("<repositories><data></data></repositories>"))
+	}))
+	defer server.Close()
+
+	n := Nexus2x{URL: server.URL, Credentials: credentials.None, HTTPClient: &http.Client{},
+		Retry: &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}}
+
+	if _, err := n.Repositories(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	if calls != 3 {
+		t.Errorf("Expected 3 calls, got %v", calls)
+	}
+}
+
+func TestFetchReportsTheAttemptsWhenItGivesUp(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	n := Nexus2x{URL: server.URL, Credentials: credentials.None, HTTPClient: &http.Client{},
+		Retry: &RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}}
+
+	_, err := n.Repositories()
+
+	var retryErr *RetryError
+	if !errors.As(err, &retryErr) || retryErr.Attempts != 2 {
+		t.Fatalf("Expected a *RetryError after 2 attempts, got %v", err)
+	}
+
+	var nexusErr Error
+	if !errors.As(err, &nexusErr) || nexusErr.StatusCode != http.StatusBadGateway {
+		t.Errorf("Expected the last attempt's error to be wrapped, got %v", retryErr.Err)
+	}
+}
+
+func TestFetchDoesntRetryWhatIsntRetryable(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	n := Nexus2x{URL: server.URL, Credentials: credentials.None, HTTPClient: &http.Client{},
+		Retry: &RetryPolicy{MaxAttempts: 5, BaseDelay: time.Millisecond}}
+
+	_, err := n.Repositories()
+	if _, ok := err.(Error); !ok {
+		t.Errorf("Expected a plain nexus.Error, got %v", err)
+	}
+
+	if calls != 1 {
+		t.Errorf("Expected 1 call, got %v", calls)
+	}
+}
+
+func TestRetryAfterTakesPrecedenceOverTheBackoff(t *testing.T) {
+	policy := &RetryPolicy{MaxAttempts: 5, BaseDelay: time.Hour}
+	response := &http.Response{Header: http.Header{"Retry-After": {"2"}}}
+
+	if delay := policy.delay(1, response); delay != 2*time.Second {
+		t.Errorf("Expected 2s, got %v", delay)
+	}
+}
+
+func TestDelayGrowsExponentiallyUpToTheCap(t *testing.T) {
+	policy := &RetryPolicy{MaxAttempts: 10, BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+
+	for attempts, max := range []time.Duration{0, time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		if attempts == 0 {
+			continue
+		}
+
+		delay := policy.delay(attempts, nil)
+		if delay < max/2 || delay > max {
+			t.Errorf("Expected a delay between %v and %v after %v attempts, got %v", max/2, max, attempts, delay)
+		}
+	}
+}
+
+func TestDelayDoesntOverflow(t *testing.T) {
+	capped := &RetryPolicy{MaxAttempts: 100, BaseDelay: time.Second, MaxDelay: time.Minute}
+	if delay := capped.delay(80, nil); delay < 30*time.Second || delay > time.Minute {
+		t.Errorf("Expected a delay between 30s and 1m, got %v", delay)
+	}
+
+	uncapped := &RetryPolicy{MaxAttempts: 100, BaseDelay: time.Second}
+	if delay := uncapped.delay(80, nil); delay < math.MaxInt64/2 {
+		t.Errorf("Expected a huge delay, got %v", delay)
+	}
+
+	immediate := &RetryPolicy{MaxAttempts: 100, MaxDelay: time.Minute}
+	for _, attempts := range []int{1, 5, 80} {
+		if delay := immediate.delay(attempts, nil); delay != 0 {
+			t.Errorf("Expected no delay after %v attempts, got %v", attempts, delay)
+		}
+	}
+}
+
+var retryableErrors = []struct {
+	err       error
+	retryable bool
+}{
+	{&url.Error{Op: "Get", URL: "http://nexus", Err: io.EOF}, true},
+	{&url.Error{Op: "Get", URL: "http://nexus", Err: io.ErrUnexpectedEOF}, true},
+	{&url.Error{Op: "Get", URL: "http://nexus", Err: &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}}, true},
+	{&url.Error{Op: "Get", URL: "http://nexus", Err: &net.DNSError{Err: "i/o timeout", Name: "nexus", IsTimeout: true}}, true},
+	{&url.Error{Op: "Get", URL: "http://nexus", Err: &net.DNSError{Err: "no such host", Name: "nexus", IsNotFound: true}}, false},
+	{&url.Error{Op: "Get", URL: "http://nexus", Err: &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}}, false},
+	{&url.Error{Op: "Get", URL: "nexus", Err: errors.New("unsupported protocol scheme")}, false},
+	{&url.Error{Op: "Get", URL: "http://nexus", Err: context.Canceled}, false},
+}
+
+func TestDefaultRetryableOnlyRetriesTransientErrors(t *testing.T) {
+	for _, test := range retryableErrors {
+		if retryable := DefaultRetryable(nil, test.err); retryable != test.retryable {
+			t.Errorf("%v: expected retryable to be %v, got %v", test.err, test.retryable, retryable)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package search
+
+import "strings"
+
+// Artifact is a Maven coordinate to a single artifact, plus the repository
+// where it came from.
+type Artifact struct {
+	GroupID      string // e.g. org.springframework
+	ArtifactID   string // e.g. spring-core
+	Version      string // e.g. 4.1.3.RELEASE
+	Classifier   string // e.g. sources, javadoc, <the empty string>...
+	Extension    string // e.g. jar
+	RepositoryID string // e.g. releases
+}
+
+// String implements the fmt.Stringer interface, as per Maven docs
+// (http://maven.apache.org/pom.html#Maven_Coordinates).
+func (a Artifact) String() string {
+	var parts = []string{a.GroupID, a.ArtifactID, a.Extension}
+
+	if a.Classifier != "" {
+		parts = append(parts, a.Classifier)
+	}
+
+	return strings.Join(append(parts, a.Version), ":") + "@" + a.RepositoryID
+}
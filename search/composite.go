@@ -0,0 +1,156 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Nexus runs only one kind of lucene search per query, picking it from the
+// parameters given, so parameters of different kinds can't go together.
+// repositoryId narrows any of them.
+var kinds = map[string]string{
+	"g":    "coordinates",
+	"a":    "coordinates",
+	"v":    "coordinates",
+	"p":    "coordinates",
+	"c":    "coordinates",
+	"q":    "keyword",
+	"cn":   "classname",
+	"sha1": "checksum",
+}
+
+// the kind of search the given parameter belongs to; unknown parameters are a
+// kind of their own.
+func kindOf(key string) string {
+	if key == "repositoryId" {
+		return ""
+	}
+
+	if kind, ok := kinds[key]; ok {
+		return kind
+	}
+
+	return key
+}
+
+// And searches for the artifacts which satisfy all of the given criteria. If
+// they all compile to parameters of the same kind of search, without
+// conflicting values (e.g. a repository and some coordinates), they're merged
+// and sent to Nexus as a single search. Otherwise, Parameters() returns nil,
+// and the client plans several searches, keeping only the artifacts found by
+// all of them; the search.Not ones are left out. An empty And is the same as
+// search.All.
+type And []Criteria
+
+// Parameters implements the search.Criteria interface, returning nil if the
+// criteria can't be merged.
+func (and And) Parameters() map[string]string {
+	merged := map[string]string{}
+	kinds := map[string]bool{}
+
+	for _, criteria := range and {
+		params := OrZero(criteria).Parameters()
+		if params == nil {
+			return nil
+		}
+
+		for key, value := range params {
+			if previous, ok := merged[key]; ok && previous != value {
+				return nil
+			}
+
+			merged[key] = value
+			if kind := kindOf(key); kind != "" {
+				kinds[kind] = true
+			}
+		}
+	}
+
+	if len(kinds) > 1 {
+		return nil
+	}
+
+	return merged
+}
+
+// String implements the fmt.Stringer interface.
+func (and And) String() string {
+	return "search.And(" + join(and) + ")"
+}
+
+// Or searches for the artifacts which satisfy any of the given criteria. The
+// client runs a search for each one, and hands over every artifact found once.
+// Parameters() returns nil, unless the criteria are all the same or one of
+// them is search.All. An empty Or finds nothing.
+type Or []Criteria
+
+// Parameters implements the search.Criteria interface, returning nil if the
+// criteria need more than one search.
+func (or Or) Parameters() map[string]string {
+	var first map[string]string
+
+	for i, criteria := range or {
+		params := OrZero(criteria).Parameters()
+		switch {
+		case params == nil:
+			return nil
+		case len(params) == 0: // search.All swallows everything else
+			return params
+		case i == 0:
+			first = params
+		case !sameParameters(first, params):
+			return nil
+		}
+	}
+
+	return first
+}
+
+// String implements the fmt.Stringer interface.
+func (or Or) String() string {
+	return "search.Or(" + join(or) + ")"
+}
+
+// Not searches for the artifacts which don't satisfy the given criteria. It's
+// meant to be used in a search.And, to leave some artifacts out (e.g.
+// everything in releases but the sources); on its own, it's the same as a full
+// search minus the given criteria's. Its Parameters() is always nil.
+type Not struct {
+	Criteria Criteria // e.g. search.ByCoordinates{Classifier: "sources"}
+}
+
+// Parameters implements the search.Criteria interface, returning nil, since
+// Nexus can't negate a search.
+func (not Not) Parameters() map[string]string {
+	return nil
+}
+
+// String implements the fmt.Stringer interface.
+func (not Not) String() string {
+	return "search.Not(" + fmt.Sprintf("%v", not.Criteria) + ")"
+}
+
+// if both maps hold the same keys and values.
+func sameParameters(a map[string]string, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for key, value := range a {
+		if other, ok := b[key]; !ok || other != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// the given criteria, separated by commas.
+func join(criteria []Criteria) string {
+	str := make([]string, len(criteria))
+	for i, c := range criteria {
+		str[i] = fmt.Sprintf("%v", OrZero(c))
+	}
+
+	return strings.Join(str, ", ")
+}
@@ -0,0 +1,187 @@
+package search
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Predicate tells which artifacts to keep from a search, for the filters Nexus
+// can't do itself (see Filtered). The predicates in this package implement the
+// fmt.Stringer interface too, so searches filtered with them can be told
+// apart (e.g. by a cache).
+type Predicate interface {
+	Matches(artifact *Artifact) bool
+}
+
+// PredicateFunc is an adapter to allow the use of ordinary functions as
+// predicates.
+type PredicateFunc func(artifact *Artifact) bool
+
+// Matches implements the search.Predicate interface, calling f(artifact).
+func (f PredicateFunc) Matches(artifact *Artifact) bool {
+	return f(artifact)
+}
+
+// Filtered searches with the given criteria, keeping only the artifacts which
+// match the given predicate. The client applies it to each page as it arrives,
+// so the artifacts left out are never piled up. Its Parameters() is always
+// nil, since Nexus knows nothing about the predicate.
+type Filtered struct {
+	Criteria  Criteria  // e.g. search.ByCoordinates{GroupID: "org.acme*"}
+	Predicate Predicate // e.g. search.ExtensionIn{"jar", "war"}
+}
+
+// Parameters implements the search.Criteria interface, returning nil.
+func (filtered Filtered) Parameters() map[string]string {
+	return nil
+}
+
+// String implements the fmt.Stringer interface.
+func (filtered Filtered) String() string {
+	return "search.Filtered(" + fmt.Sprintf("%v", OrZero(filtered.Criteria)) + ", " +
+		fmt.Sprintf("%v", filtered.Predicate) + ")"
+}
+
+// ExtensionIn matches the artifacts with one of the given extensions.
+type ExtensionIn []string
+
+// Matches implements the search.Predicate interface.
+func (extensions ExtensionIn) Matches(artifact *Artifact) bool {
+	for _, extension := range extensions {
+		if artifact.Extension == extension {
+			return true
+		}
+	}
+
+	return false
+}
+
+// String implements the fmt.Stringer interface.
+func (extensions ExtensionIn) String() string {
+	return "search.ExtensionIn(" + strings.Join(extensions, ", ") + ")"
+}
+
+// GroupIs matches the artifacts with exactly the given group ID; Nexus' g
+// matches any group ID starting with it.
+type GroupIs string
+
+// Matches implements the search.Predicate interface.
+func (groupID GroupIs) Matches(artifact *Artifact) bool {
+	return artifact.GroupID == string(groupID)
+}
+
+// String implements the fmt.Stringer interface.
+func (groupID GroupIs) String() string {
+	return "search.GroupIs(" + string(groupID) + ")"
+}
+
+// ArtifactIs matches the artifacts with exactly the given artifact ID; Nexus'
+// a matches any artifact ID starting with it.
+type ArtifactIs string
+
+// Matches implements the search.Predicate interface.
+func (artifactID ArtifactIs) Matches(artifact *Artifact) bool {
+	return artifact.ArtifactID == string(artifactID)
+}
+
+// String implements the fmt.Stringer interface.
+func (artifactID ArtifactIs) String() string {
+	return "search.ArtifactIs(" + string(artifactID) + ")"
+}
+
+// ClassifierMatches matches the artifacts whose classifier matches the given
+// regular expression. Artifacts without a classifier have "".
+type ClassifierMatches struct {
+	Pattern *regexp.Regexp // e.g. regexp.MustCompile(`^jdk1[5-8]$`)
+}
+
+// Matches implements the search.Predicate interface.
+func (classifier ClassifierMatches) Matches(artifact *Artifact) bool {
+	return classifier.Pattern.MatchString(artifact.Classifier)
+}
+
+// String implements the fmt.Stringer interface.
+func (classifier ClassifierMatches) String() string {
+	return "search.ClassifierMatches(" + classifier.Pattern.String() + ")"
+}
+
+// VersionMatches matches the artifacts whose version matches the given
+// regular expression.
+type VersionMatches struct {
+	Pattern *regexp.Regexp // e.g. regexp.MustCompile(`^1\.[0-9]+$`)
+}
+
+// Matches implements the search.Predicate interface.
+func (version VersionMatches) Matches(artifact *Artifact) bool {
+	return version.Pattern.MatchString(artifact.Version)
+}
+
+// String implements the fmt.Stringer interface.
+func (version VersionMatches) String() string {
+	return "search.VersionMatches(" + version.Pattern.String() + ")"
+}
+
+// AllOf matches the artifacts which match all of the given predicates. An
+// empty AllOf matches everything.
+type AllOf []Predicate
+
+// Matches implements the search.Predicate interface.
+func (predicates AllOf) Matches(artifact *Artifact) bool {
+	for _, predicate := range predicates {
+		if !predicate.Matches(artifact) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// String implements the fmt.Stringer interface.
+func (predicates AllOf) String() string {
+	return "search.AllOf(" + joinPredicates(predicates) + ")"
+}
+
+// AnyOf matches the artifacts which match any of the given predicates. An
+// empty AnyOf matches nothing.
+type AnyOf []Predicate
+
+// Matches implements the search.Predicate interface.
+func (predicates AnyOf) Matches(artifact *Artifact) bool {
+	for _, predicate := range predicates {
+		if predicate.Matches(artifact) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// String implements the fmt.Stringer interface.
+func (predicates AnyOf) String() string {
+	return "search.AnyOf(" + joinPredicates(predicates) + ")"
+}
+
+// NoneOf matches the artifacts which match none of the given predicates (e.g.
+// search.NoneOf{search.ExtensionIn{"pom"}} leaves the POMs out).
+type NoneOf []Predicate
+
+// Matches implements the search.Predicate interface.
+func (predicates NoneOf) Matches(artifact *Artifact) bool {
+	return !AnyOf(predicates).Matches(artifact)
+}
+
+// String implements the fmt.Stringer interface.
+func (predicates NoneOf) String() string {
+	return "search.NoneOf(" + joinPredicates(predicates) + ")"
+}
+
+// the given predicates, separated by commas.
+func joinPredicates(predicates []Predicate) string {
+	str := make([]string, len(predicates))
+	for i, predicate := range predicates {
+		str[i] = fmt.Sprintf("%v", predicate)
+	}
+
+	return strings.Join(str, ", ")
+}
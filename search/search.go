@@ -9,6 +9,10 @@ import (
 // Criteria represents a search request. It compiles to a single map with the
 // parameters Nexus expects. Nexus' API supports 4 different types of searches,
 // but in the end, all we need is a map holding the parameters to pass along.
+//
+// Some criteria can't be expressed in a single search (e.g. search.Or); their
+// Parameters() returns nil, and the client plans several searches instead,
+// combining their results.
 type Criteria interface {
 	Parameters() map[string]string
 }
@@ -166,9 +170,14 @@ type InRepository struct {
 	Criteria Criteria // e.g. search.ByKeyword("javax.enterprise")
 }
 
-// Parameters implements the search.Criteria interface.
+// Parameters implements the search.Criteria interface. It returns nil if the
+// given criteria do (e.g. a search.Or), leaving it for the client to plan.
 func (inRepo InRepository) Parameters() map[string]string {
-	params := inRepo.Criteria.Parameters()
+	params := OrZero(inRepo.Criteria).Parameters()
+	if params == nil {
+		return nil
+	}
+
 	params["repositoryId"] = inRepo.RepositoryID
 
 	return params
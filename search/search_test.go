@@ -1,6 +1,11 @@
 package search_test
 
 import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
 	"sbrubbles.org/go/nexus"
 	"sbrubbles.org/go/nexus/credentials"
 	"sbrubbles.org/go/nexus/search"
@@ -250,3 +255,152 @@ func ExampleInRepository() {
 			search.ByCoordinates{GroupID: "com.sun*", Packaging: "pom"},
 		})
 }
+
+var compositeTests = []struct {
+	criteria search.Criteria
+	expected map[string]string // nil if it can't be pushed down
+}{
+	{search.And{}, map[string]string{}},
+	{search.And{search.ByRepository("releases"), search.ByCoordinates{GroupID: "g", Classifier: "sources"}},
+		map[string]string{"repositoryId": "releases", "g": "g", "c": "sources"}},
+	{search.And{search.ByCoordinates{GroupID: "g"}, search.ByCoordinates{ArtifactID: "a"}, nil},
+		map[string]string{"g": "g", "a": "a"}},
+	{search.And{search.ByCoordinates{GroupID: "g"}, search.ByCoordinates{GroupID: "h"}}, nil},
+	{search.And{search.ByCoordinates{GroupID: "g"}, search.ByKeyword("q")}, nil},
+	{search.And{search.ByRepository("releases"), search.Not{Criteria: search.ByKeyword("q")}}, nil},
+	{search.Or{}, nil},
+	{search.Or{search.ByKeyword("q"), search.ByKeyword("q")}, map[string]string{"q": "q"}},
+	{search.Or{search.ByKeyword("q"), search.All, search.ByChecksum("abc")}, map[string]string{}},
+	{search.Or{search.ByCoordinates{GroupID: "org.foo*"}, search.ByCoordinates{GroupID: "org.bar*"}}, nil},
+	{search.Not{Criteria: search.All}, nil},
+	{search.InRepository{RepositoryID: "releases", Criteria: search.Or{search.ByKeyword("a"), search.ByKeyword("b")}}, nil},
+}
+
+func TestCompositeCriteriaArePushedDownWhenPossible(t *testing.T) {
+	for _, test := range compositeTests {
+		actual := test.criteria.Parameters()
+		if (actual == nil) != (test.expected == nil) || !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%v: expected %v, got %v", test.criteria, test.expected, actual)
+		}
+	}
+}
+
+func TestCompositeCriteriaStrings(t *testing.T) {
+	criteria := search.And{
+		search.ByRepository("releases"),
+		search.Not{Criteria: search.Or{search.ByKeyword("a"), nil}},
+	}
+
+	expected := "search.And(search.ByRepository(releases), search.Not(search.Or(search.ByKeyword(a), search.All)))"
+	if actual := fmt.Sprintf("%v", criteria); actual != expected {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+}
+
+func ExampleOr() {
+	n := nexus.New("https://maven.java.net", credentials.None)
+
+	// Returns all artifacts with a groupID starting with either org.foo or
+	// org.bar. Nexus can't do that in a single search, so the client runs one
+	// for each, and hands over every artifact found once.
+	n.Artifacts(
+		search.Or{
+			search.ByCoordinates{GroupID: "org.foo*"},
+			search.ByCoordinates{GroupID: "org.bar*"},
+		})
+}
+
+func ExampleNot() {
+	n := nexus.New("https://maven.java.net", credentials.None)
+
+	// Returns everything in releases but the sources. The client searches for
+	// the sources in releases, and leaves them out of the repository's
+	// artifacts.
+	n.Artifacts(
+		search.And{
+			search.ByRepository("releases"),
+			search.Not{Criteria: search.ByCoordinates{Classifier: "sources"}},
+		})
+}
+
+var predicateTests = []struct {
+	predicate search.Predicate
+	matched   []string // the artifacts matched, without the repository
+}{
+	{search.ExtensionIn{"jar", "war"}, []string{"org.acme:app:jar:1.0", "org.acme:app:jar:sources:1.0", "org.acme.web:site:war:2.0-beta1"}},
+	{search.GroupIs("org.acme"), []string{"org.acme:app:jar:1.0", "org.acme:app:jar:sources:1.0", "org.acme:app:pom:1.0"}},
+	{search.ArtifactIs("site"), []string{"org.acme.web:site:war:2.0-beta1"}},
+	{search.ClassifierMatches{Pattern: regexp.MustCompile(`^sour`)}, []string{"org.acme:app:jar:sources:1.0"}},
+	{search.VersionMatches{Pattern: regexp.MustCompile(`-beta`)}, []string{"org.acme.web:site:war:2.0-beta1"}},
+	{search.AllOf{search.GroupIs("org.acme"), search.NoneOf{search.ExtensionIn{"pom"}}},
+		[]string{"org.acme:app:jar:1.0", "org.acme:app:jar:sources:1.0"}},
+	{search.AnyOf{search.ExtensionIn{"pom"}, search.PredicateFunc(func(a *search.Artifact) bool { return a.Version == "2.0-beta1" })},
+		[]string{"org.acme:app:pom:1.0", "org.acme.web:site:war:2.0-beta1"}},
+	{search.AllOf{}, []string{"org.acme:app:jar:1.0", "org.acme:app:jar:sources:1.0", "org.acme:app:pom:1.0", "org.acme.web:site:war:2.0-beta1"}},
+	{search.AnyOf{}, []string{}},
+}
+
+var predicateArtifacts = []*search.Artifact{
+	{GroupID: "org.acme", ArtifactID: "app", Version: "1.0", Extension: "jar"},
+	{GroupID: "org.acme", ArtifactID: "app", Version: "1.0", Classifier: "sources", Extension: "jar"},
+	{GroupID: "org.acme", ArtifactID: "app", Version: "1.0", Extension: "pom"},
+	{GroupID: "org.acme.web", ArtifactID: "site", Version: "2.0-beta1", Extension: "war"},
+}
+
+func TestPredicatesMatchTheProperArtifacts(t *testing.T) {
+	for _, test := range predicateTests {
+		matched := []string{}
+		for _, artifact := range predicateArtifacts {
+			if test.predicate.Matches(artifact) {
+				matched = append(matched, strings.TrimSuffix(artifact.String(), "@"))
+			}
+		}
+
+		if !reflect.DeepEqual(matched, test.matched) {
+			t.Errorf("%v: expected %v, got %v", test.predicate, test.matched, matched)
+		}
+	}
+}
+
+func TestFilteredIsLeftForTheClient(t *testing.T) {
+	filtered := search.Filtered{
+		Criteria:  search.ByCoordinates{GroupID: "org.acme*"},
+		Predicate: search.AllOf{search.GroupIs("org.acme"), search.ExtensionIn{"jar", "war"}},
+	}
+
+	if params := filtered.Parameters(); params != nil {
+		t.Errorf("Expected no parameters, got %v", params)
+	}
+
+	expected := "search.Filtered(search.ByCoordinates(g: org.acme*), " +
+		"search.AllOf(search.GroupIs(org.acme), search.ExtensionIn(jar, war)))"
+	if actual := fmt.Sprintf("%v", filtered); actual != expected {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+}
+
+func ExampleFiltered() {
+	n := nexus.New("https://maven.java.net", credentials.None)
+
+	// Returns the JARs and WARs in the group com.sun, but not in its subgroups
+	// (e.g. com.sun.xml), which Nexus' search by coordinates would bring too.
+	// The artifacts which don't match are dropped as each page arrives.
+	n.Artifacts(
+		search.Filtered{
+			Criteria:  search.ByCoordinates{GroupID: "com.sun"},
+			Predicate: search.AllOf{search.GroupIs("com.sun"), search.ExtensionIn{"jar", "war"}},
+		})
+}
+
+func ExampleByVersionRange() {
+	n := nexus.New("https://maven.java.net", credentials.None)
+
+	// Returns the artifacts of com.sun.xml.bind:jaxb-impl with versions from
+	// 2.2 (inclusive) up to 2.3 (exclusive), following Maven's ordering; so
+	// 2.2.11 is in, while 2.2-rc1 and 2.3.0 aren't.
+	n.Artifacts(search.ByVersionRange{GroupID: "com.sun.xml.bind", ArtifactID: "jaxb-impl", Range: "[2.2,2.3)"})
+
+	// Ranges may be open ended, and be made of several intervals. This search
+	// returns the versions up to 1.0 (inclusive), or from 1.2 on.
+	n.Artifacts(search.ByVersionRange{GroupID: "com.sun.xml.bind", ArtifactID: "jaxb-impl", Range: "(,1.0],[1.2,)"})
+}
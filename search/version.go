@@ -0,0 +1,477 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CompareVersions compares two Maven versions, returning -1 if a comes before
+// b, 1 if it comes after, and 0 if they're the same (e.g. 1 and 1.0.0). It
+// follows Maven's own ordering
+// (https://maven.apache.org/pom.html#Version_Order_Specification):
+//   - versions are split in numbers and qualifiers, on dots, dashes and the
+//     transitions between digits and letters (e.g. 1.0beta2 is 1.0-beta-2);
+//   - numbers are compared as numbers, so 1.10 comes after 1.9;
+//   - qualifiers ignore case, and the known ones come in this order: alpha (or
+//     a), beta (or b), milestone (or m), rc (or cr), snapshot, the release
+//     itself (or ga, final, release) and sp. The unknown ones come after
+//     these, in lexical order;
+//   - a missing part is the same as 0 (or the release); so 1-beta comes before
+//     1, which comes before 1-sp and 1.1.
+func CompareVersions(a string, b string) int {
+	return parseVersion(a).compare(parseVersion(b))
+}
+
+// the kinds of items in a parsed version.
+const (
+	intItem = iota
+	stringItem
+	listItem
+)
+
+// a part of a parsed version: a number, a qualifier, or a list of items (after
+// a dash or a transition between digits and letters).
+type versionItem struct {
+	kind  int
+	value string         // the digits, without leading zeros; or the qualifier
+	items []*versionItem // the list
+}
+
+// the qualifiers Maven knows, in order; the others go after them.
+var qualifiers = []string{"alpha", "beta", "milestone", "rc", "snapshot", "", "sp"}
+
+// the qualifiers with another name.
+var qualifierAliases = map[string]string{"ga": "", "final": "", "release": "", "cr": "rc"}
+
+// the release's position in qualifiers.
+const releaseQualifier = "5"
+
+// parses the given version, as Maven's ComparableVersion does.
+func parseVersion(version string) *versionItem {
+	version = strings.ToLower(version)
+
+	root := &versionItem{kind: listItem}
+	list := root
+	lists := []*versionItem{root}
+
+	newList := func() {
+		nested := &versionItem{kind: listItem}
+		list.items = append(list.items, nested)
+		list = nested
+		lists = append(lists, nested)
+	}
+
+	isDigit := false
+	start := 0
+	for i := 0; i < len(version); i++ {
+		c := version[i]
+
+		switch {
+		case c == '.' || c == '-':
+			if i == start {
+				list.items = append(list.items, &versionItem{kind: intItem})
+			} else {
+				list.items = append(list.items, parseVersionItem(isDigit, version[start:i], false))
+			}
+
+			start = i + 1
+			if c == '-' {
+				newList()
+			}
+		case '0' <= c && c <= '9':
+			if !isDigit && i > start { // e.g. the a in 1a1
+				list.items = append(list.items, parseVersionItem(false, version[start:i], true))
+				start = i
+				newList()
+			}
+
+			isDigit = true
+		default:
+			if isDigit && i > start { // e.g. the 1 in 1a
+				list.items = append(list.items, parseVersionItem(true, version[start:i], false))
+				start = i
+				newList()
+			}
+
+			isDigit = false
+		}
+	}
+
+	if len(version) > start {
+		list.items = append(list.items, parseVersionItem(isDigit, version[start:], false))
+	}
+
+	for i := len(lists) - 1; i >= 0; i-- {
+		lists[i].normalize()
+	}
+
+	return root
+}
+
+// a number or a qualifier. Single-letter qualifiers followed by a digit are
+// abbreviations (e.g. the a in 1a1, for alpha).
+func parseVersionItem(isDigit bool, value string, followedByDigit bool) *versionItem {
+	if isDigit {
+		value = strings.TrimLeft(value, "0")
+		return &versionItem{kind: intItem, value: value}
+	}
+
+	if followedByDigit && len(value) == 1 {
+		switch value {
+		case "a":
+			value = "alpha"
+		case "b":
+			value = "beta"
+		case "m":
+			value = "milestone"
+		}
+	}
+
+	if alias, ok := qualifierAliases[value]; ok {
+		value = alias
+	}
+
+	return &versionItem{kind: stringItem, value: value}
+}
+
+// if this item is the same as a missing one.
+func (item *versionItem) isNull() bool {
+	switch item.kind {
+	case intItem, stringItem:
+		return item.value == ""
+	default:
+		return len(item.items) == 0
+	}
+}
+
+// removes the trailing items which are the same as missing ones (e.g. the
+// zeros in 1.0.0), up to the last qualifier or number.
+func (item *versionItem) normalize() {
+	for i := len(item.items) - 1; i >= 0; i-- {
+		last := item.items[i]
+		if last.isNull() {
+			item.items = append(item.items[:i], item.items[i+1:]...)
+		} else if last.kind != listItem {
+			break
+		}
+	}
+}
+
+// the qualifier, in a form which sorts in Maven's order.
+func comparableQualifier(qualifier string) string {
+	for i, known := range qualifiers {
+		if qualifier == known {
+			return fmt.Sprint(i)
+		}
+	}
+
+	return fmt.Sprintf("%d-%s", len(qualifiers), qualifier)
+}
+
+// compares this item with other, which is nil if it's missing.
+func (item *versionItem) compare(other *versionItem) int {
+	switch item.kind {
+	case intItem:
+		switch {
+		case other == nil:
+			if item.value == "" {
+				return 0
+			}
+
+			return 1
+		case other.kind == intItem:
+			return compareNumbers(item.value, other.value)
+		default: // numbers come after qualifiers and lists
+			return 1
+		}
+	case stringItem:
+		switch {
+		case other == nil: // compared with the release
+			return strings.Compare(comparableQualifier(item.value), releaseQualifier)
+		case other.kind == stringItem:
+			return strings.Compare(comparableQualifier(item.value), comparableQualifier(other.value))
+		default:
+			return -1
+		}
+	}
+
+	// a list
+	switch {
+	case other == nil:
+		for _, each := range item.items {
+			if result := each.compare(nil); result != 0 {
+				return result
+			}
+		}
+
+		return 0
+	case other.kind == intItem:
+		return -1
+	case other.kind == stringItem:
+		return 1
+	}
+
+	for i := 0; i < len(item.items) || i < len(other.items); i++ {
+		var left, right *versionItem
+		if i < len(item.items) {
+			left = item.items[i]
+		}
+
+		if i < len(other.items) {
+			right = other.items[i]
+		}
+
+		var result int
+		if left == nil {
+			result = -right.compare(nil)
+		} else {
+			result = left.compare(right)
+		}
+
+		if result != 0 {
+			return result
+		}
+	}
+
+	return 0
+}
+
+// compares two numbers without leading zeros, of any size.
+func compareNumbers(a string, b string) int {
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return -1
+		}
+
+		return 1
+	}
+
+	return strings.Compare(a, b)
+}
+
+// VersionRange is a parsed Maven version range
+// (https://maven.apache.org/enforcer/enforcer-rules/versionRanges.html), e.g.
+// [1.2,2.0) for 1.2 <= x < 2.0, or (,1.0],[1.2,) for x <= 1.0 or x >= 1.2. A
+// plain version (e.g. 1.0) means exactly that version, like [1.0] does.
+//
+// It's a Predicate, matching the artifacts whose version is in the range, and
+// its String() is the range as given. Use ParseVersionRange to build
+// instances.
+type VersionRange struct {
+	spec         string
+	restrictions []restriction
+}
+
+// a single interval in a range. Empty bounds are open.
+type restriction struct {
+	lower, upper                   string
+	lowerInclusive, upperInclusive bool
+}
+
+// if version is in this interval.
+func (r restriction) contains(version string) bool {
+	if r.lower != "" {
+		switch comparison := CompareVersions(version, r.lower); {
+		case comparison < 0, comparison == 0 && !r.lowerInclusive:
+			return false
+		}
+	}
+
+	if r.upper != "" {
+		switch comparison := CompareVersions(version, r.upper); {
+		case comparison > 0, comparison == 0 && !r.upperInclusive:
+			return false
+		}
+	}
+
+	return true
+}
+
+// RangeError is returned when a version range can't be parsed.
+type RangeError struct {
+	Range  string // e.g. [2.0,1.0]
+	Reason string // e.g. the range defies version ordering
+}
+
+// Error implements the error interface.
+func (err RangeError) Error() string {
+	return fmt.Sprintf("Invalid version range %q: %v", err.Range, err.Reason)
+}
+
+// ParseVersionRange parses the given Maven version range. The intervals must
+// be in order, and can't overlap. Returns a *RangeError if the range is
+// invalid.
+func ParseVersionRange(spec string) (VersionRange, error) {
+	fail := func(reason string) (VersionRange, error) {
+		return VersionRange{}, &RangeError{Range: spec, Reason: reason}
+	}
+
+	rest := strings.TrimSpace(spec)
+	if rest == "" {
+		return fail("it's empty")
+	}
+
+	if !strings.HasPrefix(rest, "[") && !strings.HasPrefix(rest, "(") {
+		if strings.ContainsAny(rest, "[](),") {
+			return fail("a plain version can't have brackets or commas")
+		}
+
+		return VersionRange{spec: spec, restrictions: []restriction{{rest, rest, true, true}}}, nil
+	}
+
+	restrictions := []restriction{}
+	for rest != "" {
+		end := strings.IndexAny(rest, "])")
+		if end < 0 {
+			return fail("an interval isn't closed")
+		}
+
+		r, reason := parseRestriction(rest[:end+1])
+		if reason != "" {
+			return fail(reason)
+		}
+
+		if len(restrictions) > 0 {
+			previous := restrictions[len(restrictions)-1]
+			if overlap(previous, r) {
+				return fail("the intervals overlap, or are out of order")
+			}
+		}
+
+		restrictions = append(restrictions, r)
+
+		rest = strings.TrimSpace(rest[end+1:])
+		if rest == "" {
+			break
+		}
+
+		if !strings.HasPrefix(rest, ",") {
+			return fail("the intervals must be separated by commas")
+		}
+
+		rest = strings.TrimSpace(rest[1:])
+		if !strings.HasPrefix(rest, "[") && !strings.HasPrefix(rest, "(") {
+			return fail("there's something other than an interval after a comma")
+		}
+	}
+
+	return VersionRange{spec: spec, restrictions: restrictions}, nil
+}
+
+// if the interval next doesn't start after the interval previous ends.
+func overlap(previous restriction, next restriction) bool {
+	if previous.upper == "" || next.lower == "" {
+		return true
+	}
+
+	comparison := CompareVersions(previous.upper, next.lower)
+	return comparison > 0 || comparison == 0 && previous.upperInclusive && next.lowerInclusive
+}
+
+// parses a single interval, e.g. [1.0,2.0). Returns why it's invalid, if it
+// is.
+func parseRestriction(spec string) (restriction, string) {
+	r := restriction{
+		lowerInclusive: spec[0] == '[',
+		upperInclusive: spec[len(spec)-1] == ']',
+	}
+
+	inner := strings.TrimSpace(spec[1 : len(spec)-1])
+	if strings.ContainsAny(inner, "[(") {
+		return r, "an interval isn't closed"
+	}
+
+	bounds := strings.Split(inner, ",")
+	switch len(bounds) {
+	case 1:
+		if !r.lowerInclusive || !r.upperInclusive || inner == "" {
+			return r, "a single version must be surrounded by []"
+		}
+
+		r.lower, r.upper = inner, inner
+		return r, ""
+	case 2:
+		r.lower, r.upper = strings.TrimSpace(bounds[0]), strings.TrimSpace(bounds[1])
+	default:
+		return r, "an interval has more than two bounds"
+	}
+
+	if r.lower != "" && r.upper != "" && CompareVersions(r.upper, r.lower) < 0 {
+		return r, "the range defies version ordering"
+	}
+
+	return r, ""
+}
+
+// Matches implements the search.Predicate interface, matching the artifacts
+// whose version is in this range.
+func (versions VersionRange) Matches(artifact *Artifact) bool {
+	return versions.Contains(artifact.Version)
+}
+
+// Contains tells if the given version is in this range.
+func (versions VersionRange) Contains(version string) bool {
+	for _, r := range versions.restrictions {
+		if r.contains(version) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// String implements the fmt.Stringer interface, returning the range as given.
+func (versions VersionRange) String() string {
+	return versions.spec
+}
+
+// ByVersionRange searches for the artifacts of the given group and artifact
+// IDs whose version is in the given Maven version range (see VersionRange),
+// e.g. [1.2,2.0). Nexus can't search by range, so its Parameters() is nil,
+// and the client searches by the coordinates, keeping only the versions in
+// the range (see Filtered).
+type ByVersionRange struct {
+	GroupID    string // e.g. com.atlassian.maven.plugins
+	ArtifactID string // e.g. maven-jgitflow-plugin
+	Range      string // e.g. [1.0,2.0), (,1.0],[1.2,)...
+}
+
+// Parameters implements the search.Criteria interface, returning nil.
+func (byRange ByVersionRange) Parameters() map[string]string {
+	return nil
+}
+
+// Filtered returns the search the client runs instead: by the coordinates,
+// filtered by the exact group and artifact IDs (Nexus takes them as prefixes)
+// and by the range. Returns a *RangeError if the range is invalid, or if there
+// are neither group nor artifact IDs, since Nexus would have to list
+// everything.
+func (byRange ByVersionRange) Filtered() (Filtered, error) {
+	if byRange.GroupID == "" && byRange.ArtifactID == "" {
+		return Filtered{}, &RangeError{Range: byRange.Range, Reason: "there are neither group nor artifact IDs"}
+	}
+
+	versions, err := ParseVersionRange(byRange.Range)
+	if err != nil {
+		return Filtered{}, err
+	}
+
+	predicate := AllOf{}
+	if byRange.GroupID != "" {
+		predicate = append(predicate, GroupIs(byRange.GroupID))
+	}
+
+	if byRange.ArtifactID != "" {
+		predicate = append(predicate, ArtifactIs(byRange.ArtifactID))
+	}
+
+	return Filtered{
+		Criteria:  ByCoordinates{GroupID: byRange.GroupID, ArtifactID: byRange.ArtifactID},
+		Predicate: append(predicate, versions),
+	}, nil
+}
+
+// String implements the fmt.Stringer interface.
+func (byRange ByVersionRange) String() string {
+	return "search.ByVersionRange(" + byRange.GroupID + ":" + byRange.ArtifactID + ", " + byRange.Range + ")"
+}
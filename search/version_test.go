@@ -0,0 +1,141 @@
+package search_test
+
+import (
+	"testing"
+
+	"sbrubbles.org/go/nexus/search"
+)
+
+// in increasing order, from Maven's own tests.
+var orderedVersions = [][]string{
+	{"1-alpha2snapshot", "1-alpha2", "1-alpha-123", "1-beta-2", "1-beta123", "1-m2", "1-m11", "1-rc", "1-cr2",
+		"1-rc123", "1-SNAPSHOT", "1", "1-sp", "1-sp2", "1-sp123", "1-abc", "1-def", "1-pom-1", "1-1-snapshot",
+		"1-1", "1-2", "1-123"},
+	{"2.0", "2-1", "2.0.a", "2.0.0.a", "2.0.2", "2.0.123", "2.1.0", "2.1-a", "2.1b", "2.1-c", "2.1-1", "2.1.0.1",
+		"2.2", "2.123", "11.a2", "11.a11", "11.b2", "11.b11", "11.m2", "11.m11", "11", "11.a", "11b", "11c", "11m"},
+	{"1.9", "1.10", "1.99999999999999999999", "2.0-alpha-1", "2.0-beta1", "2.0-RC1", "2.0-SNAPSHOT", "2.0.0.Final"},
+}
+
+// the same, from Maven's own tests.
+var sameVersions = [][]string{
+	{"1", "1.0", "1.0.0", "1-0", "1.0-0", "1ga", "1.0.ga", "1-GA", "1final", "1-Final", "1release"},
+	{"1a", "1-a", "1.0-a", "1.0.0-a"},
+	{"1x", "1-x", "1.0x", "1.0.0-x"},
+	{"1cr", "1rc", "1-CR"},
+	{"1a1", "1-alpha-1", "1.0alpha1"},
+	{"1b2", "1-beta-2"},
+	{"1m3", "1-milestone-3"},
+}
+
+func TestCompareVersionsFollowsMavensOrder(t *testing.T) {
+	for _, versions := range orderedVersions {
+		for i := range versions {
+			for j := range versions {
+				expected := 0
+				switch {
+				case i < j:
+					expected = -1
+				case i > j:
+					expected = 1
+				}
+
+				if actual := search.CompareVersions(versions[i], versions[j]); actual != expected {
+					t.Errorf("Comparing %v with %v: expected %v, got %v", versions[i], versions[j], expected, actual)
+				}
+			}
+		}
+	}
+
+	for _, versions := range sameVersions {
+		for _, a := range versions {
+			for _, b := range versions {
+				if actual := search.CompareVersions(a, b); actual != 0 {
+					t.Errorf("Expected %v and %v to be the same, got %v", a, b, actual)
+				}
+			}
+		}
+	}
+}
+
+var versionRangeTests = []struct {
+	spec  string
+	in    []string
+	notIn []string
+}{
+	{"[1.2,2.0)", []string{"1.2", "1.2.0", "1.5-SNAPSHOT", "1.10", "2.0-alpha-1", "2.0-SNAPSHOT"}, []string{"1.1", "1.2-rc1", "2.0", "2.0.0", "2.1"}},
+	{"(1.2,2.0]", []string{"1.2.1", "1.2-sp", "2.0", "2.0.ga"}, []string{"1.2", "2.0-sp", "2.0.1"}},
+	{"[1.0]", []string{"1.0", "1", "1.0.0"}, []string{"1.0.1", "1.0-SNAPSHOT"}},
+	{"1.0", []string{"1.0", "1.0.0"}, []string{"1.1"}},
+	{"(,1.0]", []string{"0.1", "1.0-beta", "1.0"}, []string{"1.0.1"}},
+	{"[1.5,)", []string{"1.5", "10"}, []string{"1.4", "1.5-rc1"}},
+	{"(,1.0],[1.2,)", []string{"0.9", "1.0", "1.2", "3"}, []string{"1.1", "1.2-beta"}},
+	{" ( , 1.1 ) , ( 1.1 , ) ", []string{"1.0", "1.2"}, []string{"1.1"}},
+}
+
+func TestVersionRangesHoldTheProperVersions(t *testing.T) {
+	for _, test := range versionRangeTests {
+		versions, err := search.ParseVersionRange(test.spec)
+		if err != nil {
+			t.Fatalf("%v: unexpected error %v", test.spec, err)
+		}
+
+		for _, version := range test.in {
+			if !versions.Contains(version) || !versions.Matches(&search.Artifact{Version: version}) {
+				t.Errorf("Expected %v in %v", version, test.spec)
+			}
+		}
+
+		for _, version := range test.notIn {
+			if versions.Contains(version) {
+				t.Errorf("Didn't expect %v in %v", version, test.spec)
+			}
+		}
+	}
+}
+
+func TestInvalidVersionRangesFail(t *testing.T) {
+	for _, spec := range []string{
+		"", "[1.0", "(1.0)", "[1.0)", "[2.0,1.0]", "[1,2,3]", "[1.0,2.0),1.5", "[1.0,2.0)[3.0,)",
+		"[1.0,2.0],[1.5,3.0]", "[1.0,1.1],[1.1,2.0]", "[2.0,),[1.0,1.5]", "(,1.0],(,2.0]", "1.0,2.0", "[[1.0]]",
+	} {
+		if _, err := search.ParseVersionRange(spec); err == nil {
+			t.Errorf("%q: expected an error", spec)
+		} else if _, ok := err.(*search.RangeError); !ok {
+			t.Errorf("%q: expected a *search.RangeError, got %v", spec, err)
+		}
+	}
+}
+
+func TestByVersionRangeIsAFilteredSearch(t *testing.T) {
+	byRange := search.ByVersionRange{GroupID: "g", ArtifactID: "a", Range: "[1.2,2.0)"}
+	if params := byRange.Parameters(); params != nil {
+		t.Errorf("Expected no parameters, got %v", params)
+	}
+
+	filtered, err := byRange.Filtered()
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	if filtered.Criteria != (search.ByCoordinates{GroupID: "g", ArtifactID: "a"}) {
+		t.Errorf("Unexpected criteria %v", filtered.Criteria)
+	}
+
+	for _, artifact := range []*search.Artifact{
+		{GroupID: "g", ArtifactID: "a", Version: "2.0"},
+		{GroupID: "g", ArtifactID: "ab", Version: "1.3"}, // Nexus' a=a matches it
+		{GroupID: "g.h", ArtifactID: "a", Version: "1.3"},
+	} {
+		if filtered.Predicate.Matches(artifact) {
+			t.Errorf("%v matched %v", filtered.Predicate, artifact)
+		}
+	}
+
+	if !filtered.Predicate.Matches(&search.Artifact{GroupID: "g", ArtifactID: "a", Version: "1.3"}) {
+		t.Errorf("Unexpected predicate %v", filtered.Predicate)
+	}
+
+	if _, err := (search.ByVersionRange{Range: "[1.0,)"}).Filtered(); err == nil {
+		t.Errorf("Expected an error without group and artifact IDs")
+	}
+}